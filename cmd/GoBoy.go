@@ -3,30 +3,155 @@ package main
 import (
 	"GoBoy/internal"
 	"GoBoy/memory"
+	"GoBoy/romdb"
+	"bufio"
+	"flag"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const (
+	// saveInterval is how often battery backed RAM is flushed to disk, so
+	// a crash loses at most this much progress
+	saveInterval = 10 * time.Second
+
+	// frameCycles is the M-cycles in one frame, how often the main loop
+	// checks for work outside the emulation
+	frameCycles = 17556
 )
 
 func main() {
-	fmt.Println("Starting GoBoy Emulator")
+	traceFile := flag.String("trace", "", "write a gameboy-doctor instruction trace to this file")
+	bootROMFile := flag.String("bootrom", "", "start from this boot ROM image instead of the post-boot state")
+	emulatedRTC := flag.Bool("emulated-rtc", false, "drive the cartridge clock from emulated cycles instead of wall time")
+	modelName := flag.String("model", "DMG", "hardware model: DMG0, DMG, MGB, SGB, SGB2, CGB or AGB")
+	patchFile := flag.String("patch", "", "IPS, UPS or BPS patch to apply, defaults to one with the ROM's name if it exists")
+	datFile := flag.String("romdb", "", "No-Intro DAT file to identify the ROM with, on top of the built-in database")
+	saveFile := flag.String("save", "", "battery save file, defaults to the ROM's name with a .sav extension")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] rom.gb|rom.zip|rom.gz\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	romPath := flag.Arg(0)
 
-	cart, err := memory.LoadCartridge("Tetris.gb")
+	model, err := memory.ParseModel(*modelName)
 	if err != nil {
 		fmt.Println("Error:", err)
 		return
+	}
+
+	fmt.Println("Starting GoBoy Emulator")
+
+	var cart *memory.Cartridge
+	if *patchFile != "" {
+		cart, err = memory.LoadPatchedCartridge(romPath, *patchFile)
 	} else {
-		cart.Debug()
+		cart, err = memory.LoadCartridge(romPath)
+	}
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
 	}
 
-	m := memory.NewMemory(cart)
+	if *datFile != "" {
+		db := romdb.Embedded()
+		if err := db.LoadFile(*datFile); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		cart.Identify(db)
+	}
+	cart.Debug()
+
+	m, err := memory.NewMemoryWithModel(cart, model)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	if *emulatedRTC {
+		m.SetRTCClock(memory.RTCEmulatedClock)
+	}
+
+	if *saveFile == "" {
+		*saveFile = memory.SavePath(romPath)
+	}
+	if err := m.LoadSaveFile(*saveFile); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	defer func() {
+		if err := m.WriteSaveFile(*saveFile); err != nil {
+			fmt.Println("Error:", err)
+		}
+	}()
+
+	if *bootROMFile != "" {
+		bootROM, err := os.ReadFile(*bootROMFile)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if err := m.LoadBootROM(bootROM); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+	}
 
 	cpu := internal.NewCPU(m)
-	cpu.InitOpcodeTable()
-	cpu.InitOpcodeCBTable()
 
+	if *traceFile != "" {
+		f, err := os.Create(*traceFile)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		defer f.Close()
+
+		w := bufio.NewWriter(f)
+		defer w.Flush()
+		cpu.SetTracer(internal.NewDoctorTracer(w))
+	}
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+
+	lastSave := time.Now()
+	frameProgress := 0
 	for {
-		err := cpu.Cycle()
-		if err {
+		cycles, err := cpu.Step()
+		if err != nil {
+			fmt.Println("Error:", err)
 			break
 		}
+		m.Tick(cycles)
+
+		frameProgress += cycles
+		if frameProgress < frameCycles {
+			continue
+		}
+		frameProgress -= frameCycles
+
+		select {
+		case <-interrupted:
+			return
+		default:
+		}
+
+		if time.Since(lastSave) >= saveInterval {
+			if err := m.WriteSaveFile(*saveFile); err != nil {
+				fmt.Println("Error:", err)
+			}
+			lastSave = time.Now()
+		}
 	}
 }
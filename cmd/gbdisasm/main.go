@@ -0,0 +1,134 @@
+package main
+
+import (
+	"GoBoy/disasm"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	banks := flag.String("bank", "0", "ROM bank or inclusive bank range to disassemble, e.g. 1 or 0-3")
+	start := flag.String("start", "", "first address within each bank's window (default start of window)")
+	end := flag.String("end", "", "last address within each bank's window (default end of window)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: gbdisasm [flags] rom.gb")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	rom, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+
+	first, last, err := parseBankRange(*banks)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+
+	// Check every bank before printing any, bank 0 and the switchable
+	// banks being mapped at different addresses
+	if last*0x4000 >= len(rom) {
+		fmt.Fprintf(os.Stderr, "Error: bank %d is past the end of the ROM\n", last)
+		os.Exit(1)
+	}
+	for _, bank := range []int{first, last} {
+		if _, _, err := parseWindow(*start, *end, bankBase(bank)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: bank %d: %v\n", bank, err)
+			os.Exit(1)
+		}
+	}
+
+	for bank := first; bank <= last; bank++ {
+		offset := bank * 0x4000
+		data := rom[offset:min(offset+0x4000, len(rom))]
+
+		base := bankBase(bank)
+		from, to, _ := parseWindow(*start, *end, base)
+
+		bus := disasm.Bytes{Data: data, Base: base}
+		for _, inst := range disasm.Disassemble(bus, from, to) {
+			fmt.Printf("%02X:%04X  %-9s  %s\n", bank, inst.Address, hexBytes(inst.Bytes), inst)
+		}
+	}
+}
+
+func parseBankRange(s string) (int, int, error) {
+	firstStr, lastStr, isRange := strings.Cut(s, "-")
+	first, err := strconv.Atoi(firstStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid bank %q", s)
+	}
+	if !isRange {
+		return first, first, nil
+	}
+
+	last, err := strconv.Atoi(lastStr)
+	if err != nil || last < first {
+		return 0, 0, fmt.Errorf("invalid bank range %q", s)
+	}
+	return first, last, nil
+}
+
+// bankBase returns the address a bank is mapped at. Bank 0 is fixed at
+// 0x0000, every other bank is switched in at 0x4000.
+func bankBase(bank int) uint16 {
+	if bank > 0 {
+		return 0x4000
+	}
+	return 0x0000
+}
+
+// parseWindow parses the -start and -end addresses for a bank mapped at
+// base, which both have to fall within its 16KB window
+func parseWindow(startStr, endStr string, base uint16) (uint16, uint16, error) {
+	last := base + 0x3FFF
+	from, err := parseAddress(startStr, base)
+	if err != nil {
+		return 0, 0, err
+	}
+	to, err := parseAddress(endStr, last)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, addr := range []uint16{from, to} {
+		if addr < base || addr > last {
+			return 0, 0, fmt.Errorf("address 0x%04X is outside the bank's window 0x%04X-0x%04X", addr, base, last)
+		}
+	}
+	if to < from {
+		return 0, 0, fmt.Errorf("end address 0x%04X is before start address 0x%04X", to, from)
+	}
+	return from, to, nil
+}
+
+func parseAddress(s string, fallback uint16) (uint16, error) {
+	if s == "" {
+		return fallback, nil
+	}
+
+	addr, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address %q", s)
+	}
+	return uint16(addr), nil
+}
+
+func hexBytes(data []byte) string {
+	parts := make([]string, len(data))
+	for i, b := range data {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,148 @@
+// Package disasm decodes SM83 machine code into mnemonics, for tracing,
+// debugging and inspecting ROMs.
+package disasm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Bus is anything instructions can be read from. *memory.Memory satisfies
+// it, and Bytes adapts a plain byte slice.
+type Bus interface {
+	Read(addr uint16) byte
+}
+
+// Bytes is a Bus over a byte slice whose first byte sits at address Base.
+// Addresses outside the slice read as 0xFF, like an open bus.
+type Bytes struct {
+	Data []byte
+	Base uint16
+}
+
+func (b Bytes) Read(addr uint16) byte {
+	offset := int(addr - b.Base)
+	if offset < len(b.Data) {
+		return b.Data[offset]
+	}
+	return 0xFF
+}
+
+// Instruction is one decoded instruction
+type Instruction struct {
+	Address  uint16
+	Bytes    []byte   // Raw encoding, including any 0xCB prefix
+	Mnemonic string   // e.g. "LD", or "DB" for an illegal opcode
+	Operands []string // e.g. ["A", "($C000)"], immediates already filled in
+	Length   int
+	Cycles   int  // Machine cycles, branch not taken for conditional branches
+	Taken    int  // Machine cycles when the branch is taken, same as Cycles otherwise
+	Prefixed bool // Decoded from the 0xCB table
+	Illegal  bool // One of the opcodes that lock up the CPU
+}
+
+func (inst Instruction) String() string {
+	if len(inst.Operands) == 0 {
+		return inst.Mnemonic
+	}
+	return inst.Mnemonic + " " + strings.Join(inst.Operands, ",")
+}
+
+// Decode decodes the instruction at addr
+func Decode(bus Bus, addr uint16) Instruction {
+	opcode := bus.Read(addr)
+	info := baseTable[opcode]
+	prefixed := false
+
+	if opcode == 0xCB {
+		info = cbTable[bus.Read(addr+1)]
+		prefixed = true
+	}
+
+	if info.mnemonic == "" {
+		return Instruction{
+			Address:  addr,
+			Bytes:    []byte{opcode},
+			Mnemonic: "DB",
+			Operands: []string{fmt.Sprintf("$%02X", opcode)},
+			Length:   1,
+			Cycles:   1,
+			Taken:    1,
+			Illegal:  true,
+		}
+	}
+
+	raw := make([]byte, info.length)
+	for i := range raw {
+		raw[i] = bus.Read(addr + uint16(i))
+	}
+
+	taken := info.taken
+	if taken == 0 {
+		taken = info.cycles
+	}
+
+	inst := Instruction{
+		Address:  addr,
+		Bytes:    raw,
+		Mnemonic: info.mnemonic,
+		Length:   info.length,
+		Cycles:   info.cycles,
+		Taken:    taken,
+		Prefixed: prefixed,
+	}
+	if info.operands != "" {
+		for _, operand := range strings.Split(info.operands, ",") {
+			inst.Operands = append(inst.Operands, formatOperand(operand, raw, addr))
+		}
+	}
+	return inst
+}
+
+// DecodeBytes decodes the instruction at the start of data, taking it to be
+// located at addr
+func DecodeBytes(data []byte, addr uint16) Instruction {
+	return Decode(Bytes{Data: data, Base: addr}, addr)
+}
+
+// Disassemble decodes instructions from start up to and including end
+func Disassemble(bus Bus, start, end uint16) []Instruction {
+	var insts []Instruction
+	for addr := uint32(start); addr <= uint32(end); {
+		inst := Decode(bus, uint16(addr))
+		insts = append(insts, inst)
+		addr += uint32(inst.Length)
+	}
+	return insts
+}
+
+// formatOperand fills the immediate placeholder in an operand template with
+// the value from the instruction's encoding
+func formatOperand(operand string, raw []byte, addr uint16) string {
+	switch {
+	case strings.Contains(operand, "d16"):
+		return strings.Replace(operand, "d16", fmt.Sprintf("$%04X", imm16(raw)), 1)
+	case strings.Contains(operand, "a16"):
+		return strings.Replace(operand, "a16", fmt.Sprintf("$%04X", imm16(raw)), 1)
+	case strings.Contains(operand, "d8"):
+		return strings.Replace(operand, "d8", fmt.Sprintf("$%02X", raw[1]), 1)
+	case strings.Contains(operand, "a8"):
+		return strings.Replace(operand, "a8", fmt.Sprintf("$FF%02X", raw[1]), 1)
+	case strings.Contains(operand, "r8"):
+		// Relative jumps are shown as their absolute target
+		target := addr + 2 + uint16(int8(raw[1]))
+		return strings.Replace(operand, "r8", fmt.Sprintf("$%04X", target), 1)
+	case strings.Contains(operand, "e8"):
+		// Signed offsets, SP+e8 becomes SP-$xx when negative
+		if offset := int8(raw[1]); offset < 0 {
+			operand = strings.Replace(operand, "+e8", "e8", 1)
+			return strings.Replace(operand, "e8", fmt.Sprintf("-$%02X", -int(offset)), 1)
+		}
+		return strings.Replace(operand, "e8", fmt.Sprintf("$%02X", raw[1]), 1)
+	}
+	return operand
+}
+
+func imm16(raw []byte) uint16 {
+	return uint16(raw[2])<<8 | uint16(raw[1])
+}
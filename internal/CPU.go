@@ -9,12 +9,43 @@ type CPU struct {
 	A, B, C, D, E, H, L, F uint8
 	SP, PC                 uint16
 	IME                    bool
+	eiDelay                bool
+	halted                 bool
+	haltBug                bool
+	stopped                bool
+	fault                  error
 	memory                 *memory.Memory
+	tracer                 Tracer
+
+	// Dispatch tables are bound to this CPU's handlers
+	opcodeTable   [256]opcodeFunc
+	opcodeCBTable [256]opcodeFunc
+
+	// Cycles is the number of machine cycles (M-cycles) executed since
+	// power on. One M-cycle is 4 T-cycles of the 4.194304 MHz clock.
+	Cycles uint64
 }
 
+// speedSwitchCycles is how long the CPU stays stopped during a CGB speed
+// switch, 8200 T-cycles
+const speedSwitchCycles = 2050
+
+// TCyclesPerMCycle converts the machine cycles reported by Cycle into
+// clock (T) cycles.
+const TCyclesPerMCycle = 4
+
+// NewCPU returns a CPU in the state the boot ROM of the memory's model
+// leaves it, about to execute the cartridge entry point at 0x0100. When a
+// boot ROM is mapped, the CPU starts from power on at 0x0000 instead.
 func NewCPU(m *memory.Memory) *CPU {
 	fmt.Println("Initializing CPU")
-	return &CPU{PC: 0x0100, memory: m}
+	cpu := &CPU{memory: m}
+	if !m.BootROMMapped() {
+		cpu.resetRegisters()
+	}
+	cpu.initOpcodeTable()
+	cpu.initOpcodeCBTable()
+	return cpu
 }
 
 func (cpu *CPU) AF() uint16 {
@@ -102,733 +133,2413 @@ func (cpu *CPU) GetSubtractFlag() bool {
 }
 
 func (cpu *CPU) PushStack(value uint16) {
-	cpu.SP--
-	cpu.memory.Write(cpu.SP, byte(value&0xFF))
 	cpu.SP--
 	cpu.memory.Write(cpu.SP, byte((value>>8)&0xFF))
+	cpu.SP--
+	cpu.memory.Write(cpu.SP, byte(value&0xFF))
+}
+
+func (cpu *CPU) popStack() uint16 {
+	low := cpu.memory.Read(cpu.SP)
+	cpu.SP++
+	high := cpu.memory.Read(cpu.SP)
+	cpu.SP++
+	return uint16(high)<<8 | uint16(low)
 }
 
-func (cpu *CPU) Cycle() bool {
+// Step executes one instruction and returns the number of machine cycles
+// it took, so other components can be stepped by the same amount. A non-nil
+// error means execution cannot continue; it is an *UnimplementedOpcodeError
+// or *IllegalOpcodeError and is returned again by every later call.
+func (cpu *CPU) Step() (int, error) {
+	if cpu.fault != nil {
+		return 0, cpu.fault
+	}
+
+	if cycles := cpu.handleInterrupts(); cycles > 0 {
+		cpu.Cycles += uint64(cycles)
+		return cycles, nil
+	}
+
+	if cpu.stopped && cpu.memory.JoypadInput() {
+		cpu.stopped = false
+	}
+
+	if cpu.halted || cpu.stopped {
+		// The clock keeps running while the CPU idles
+		cycles := 1 + cpu.memory.TakeStall()
+		cpu.Cycles += uint64(cycles)
+		return cycles, nil
+	}
+
 	opcode := cpu.memory.Read(cpu.PC)
-	handler := opcodeTable[opcode]
+	handler := cpu.opcodeTable[opcode]
 
-	if handler != nil {
-		fmt.Printf("Executing opcode 0x%02X at PC 0x%04X\n", opcode, cpu.PC)
-		handler()
-	} else {
-		fmt.Printf("Unhandled opcode 0x%02X at PC 0x%04X\n", opcode, cpu.PC)
-		return true
+	if handler == nil {
+		cpu.fault = &UnimplementedOpcodeError{Opcode: opcode, PC: cpu.PC}
+		return 0, cpu.fault
+	}
+
+	if cpu.tracer != nil {
+		cpu.tracer.Trace(cpu)
+	}
+
+	if cpu.haltBug {
+		// The opcode fetch failed to advance PC, so the handler reads the
+		// opcode byte a second time as the start of its operands
+		cpu.haltBug = false
+		cpu.PC--
+	}
+
+	// EI takes effect after the instruction following it has executed
+	enableIME := cpu.eiDelay
+
+	// VRAM DMA started or continued by the instruction stalls the CPU
+	cycles := handler() + cpu.memory.TakeStall()
+	cpu.Cycles += uint64(cycles)
+
+	if enableIME && cpu.eiDelay {
+		cpu.IME = true
+		cpu.eiDelay = false
 	}
-	return false
+	return cycles, cpu.fault
 }
 
 // Opcode Handling //
-type opcodeFunc func()
-
-var opcodeTable [256]opcodeFunc
-
-func (cpu *CPU) InitOpcodeTable() {
-	opcodeTable[0x00] = cpu.NOP
-	opcodeTable[0x01] = cpu.LD_BC_u16
-	opcodeTable[0x03] = cpu.INC_BC
-	opcodeTable[0x07] = cpu.RLCA
-	opcodeTable[0x0F] = cpu.RRCA
-	opcodeTable[0x11] = cpu.LD_DE_u16
-	opcodeTable[0x13] = cpu.INC_DE
-	opcodeTable[0x1F] = cpu.RRA
-	opcodeTable[0x20] = cpu.JR_NZ_r8
-	opcodeTable[0x21] = cpu.LD_HL_u16
-	opcodeTable[0x22] = cpu.LD_HLi_A
-	opcodeTable[0x25] = cpu.DEC_H
-	opcodeTable[0x26] = cpu.LD_H_u8
-	opcodeTable[0x2C] = cpu.INC_L
-	opcodeTable[0x2D] = cpu.DEC_L
-	opcodeTable[0x2F] = cpu.CPL
-	opcodeTable[0x30] = cpu.JR_NC_r8
-	opcodeTable[0x31] = cpu.LD_SP_u16
-	opcodeTable[0x3C] = cpu.INC_A
-	opcodeTable[0x3E] = cpu.LD_A_u8
-	opcodeTable[0x40] = cpu.LD_B_B
-	opcodeTable[0x46] = cpu.LD_B_HL
-	opcodeTable[0x47] = cpu.LD_B_A
-	opcodeTable[0x4E] = cpu.LD_C_HL
-	opcodeTable[0x4F] = cpu.LD_C_A
-	opcodeTable[0x55] = cpu.LD_D_L
-	opcodeTable[0x56] = cpu.LD_D_HL
-	opcodeTable[0x57] = cpu.LD_D_A
-	opcodeTable[0x5F] = cpu.LD_E_A
-	opcodeTable[0x67] = cpu.LD_H_A
-	opcodeTable[0x6F] = cpu.LD_L_A
-	opcodeTable[0x70] = cpu.LD_HL_B
-	opcodeTable[0x71] = cpu.LD_HL_C
-	opcodeTable[0x72] = cpu.LD_HL_D
-	opcodeTable[0x78] = cpu.LD_A_B
-	opcodeTable[0x79] = cpu.LD_A_C
-	opcodeTable[0x7A] = cpu.LD_A_D
-	opcodeTable[0x7B] = cpu.LD_A_E
-	opcodeTable[0x80] = cpu.ADD_A_B
-	opcodeTable[0x81] = cpu.ADD_A_C
-	opcodeTable[0x82] = cpu.ADD_A_D
-	opcodeTable[0x83] = cpu.ADD_A_E
-	opcodeTable[0xAE] = cpu.XOR_A_HL
-	opcodeTable[0xB9] = cpu.CP_A_C
-	opcodeTable[0xC1] = cpu.POP_BC
-	opcodeTable[0xC3] = cpu.JP_u16
-	opcodeTable[0xC5] = cpu.PUSH_BC
-	opcodeTable[0xC9] = cpu.RET
-	opcodeTable[0xCB] = cpu.ExecuteCBOpcode
-	opcodeTable[0xCD] = cpu.CALL_u16
-	opcodeTable[0xD1] = cpu.POP_DE
-	opcodeTable[0xD5] = cpu.PUSH_DE
-	opcodeTable[0xE0] = cpu.LD_u8C_A
-	opcodeTable[0xE1] = cpu.POP_HL
-	opcodeTable[0xE5] = cpu.PUSH_HL
-	opcodeTable[0xEA] = cpu.LD_u16_A
-	opcodeTable[0xEE] = cpu.XOR_A_u8
-	opcodeTable[0xF0] = cpu.LD_A_u8C
-	opcodeTable[0xF1] = cpu.POP_AF
-	opcodeTable[0xF3] = cpu.DI
-	opcodeTable[0xF5] = cpu.PUSH_AF
-	opcodeTable[0xF9] = cpu.LD_SP_HL
-	opcodeTable[0xFA] = cpu.LD_A_u16
-	opcodeTable[0xFF] = cpu.RST_38H
-}
-
-var opcodeCBTable [256]opcodeFunc
-
-func (cpu *CPU) InitOpcodeCBTable() {
-	opcodeCBTable[0x19] = cpu.RR_C
-	opcodeCBTable[0x1A] = cpu.RR_D
-	opcodeCBTable[0x38] = cpu.SRL_B
-}
-
-func (cpu *CPU) NOP() {
+
+// opcodeFunc executes one instruction and returns the machine cycles it took
+type opcodeFunc func() int
+
+func (cpu *CPU) initOpcodeTable() {
+	cpu.opcodeTable[0x00] = cpu.NOP
+	cpu.opcodeTable[0x01] = cpu.LD_BC_u16
+	cpu.opcodeTable[0x02] = cpu.LD_BC_A
+	cpu.opcodeTable[0x03] = cpu.INC_BC
+	cpu.opcodeTable[0x04] = cpu.INC_B
+	cpu.opcodeTable[0x05] = cpu.DEC_B
+	cpu.opcodeTable[0x06] = cpu.LD_B_u8
+	cpu.opcodeTable[0x07] = cpu.RLCA
+	cpu.opcodeTable[0x08] = cpu.LD_u16_SP
+	cpu.opcodeTable[0x09] = cpu.ADD_HL_BC
+	cpu.opcodeTable[0x0A] = cpu.LD_A_BC
+	cpu.opcodeTable[0x0B] = cpu.DEC_BC
+	cpu.opcodeTable[0x0C] = cpu.INC_C
+	cpu.opcodeTable[0x0D] = cpu.DEC_C
+	cpu.opcodeTable[0x0E] = cpu.LD_C_u8
+	cpu.opcodeTable[0x0F] = cpu.RRCA
+	cpu.opcodeTable[0x10] = cpu.STOP
+	cpu.opcodeTable[0x11] = cpu.LD_DE_u16
+	cpu.opcodeTable[0x12] = cpu.LD_DE_A
+	cpu.opcodeTable[0x13] = cpu.INC_DE
+	cpu.opcodeTable[0x14] = cpu.INC_D
+	cpu.opcodeTable[0x15] = cpu.DEC_D
+	cpu.opcodeTable[0x16] = cpu.LD_D_u8
+	cpu.opcodeTable[0x17] = cpu.RLA
+	cpu.opcodeTable[0x18] = cpu.JR_r8
+	cpu.opcodeTable[0x19] = cpu.ADD_HL_DE
+	cpu.opcodeTable[0x1A] = cpu.LD_A_DE
+	cpu.opcodeTable[0x1B] = cpu.DEC_DE
+	cpu.opcodeTable[0x1C] = cpu.INC_E
+	cpu.opcodeTable[0x1D] = cpu.DEC_E
+	cpu.opcodeTable[0x1E] = cpu.LD_E_u8
+	cpu.opcodeTable[0x1F] = cpu.RRA
+	cpu.opcodeTable[0x20] = cpu.JR_NZ_r8
+	cpu.opcodeTable[0x21] = cpu.LD_HL_u16
+	cpu.opcodeTable[0x22] = cpu.LD_HLi_A
+	cpu.opcodeTable[0x23] = cpu.INC_HL
+	cpu.opcodeTable[0x24] = cpu.INC_H
+	cpu.opcodeTable[0x25] = cpu.DEC_H
+	cpu.opcodeTable[0x26] = cpu.LD_H_u8
+	cpu.opcodeTable[0x27] = cpu.DAA
+	cpu.opcodeTable[0x28] = cpu.JR_Z_r8
+	cpu.opcodeTable[0x29] = cpu.ADD_HL_HL
+	cpu.opcodeTable[0x2A] = cpu.LD_A_HLi
+	cpu.opcodeTable[0x2B] = cpu.DEC_HL
+	cpu.opcodeTable[0x2C] = cpu.INC_L
+	cpu.opcodeTable[0x2D] = cpu.DEC_L
+	cpu.opcodeTable[0x2E] = cpu.LD_L_u8
+	cpu.opcodeTable[0x2F] = cpu.CPL
+	cpu.opcodeTable[0x30] = cpu.JR_NC_r8
+	cpu.opcodeTable[0x31] = cpu.LD_SP_u16
+	cpu.opcodeTable[0x32] = cpu.LD_HLd_A
+	cpu.opcodeTable[0x33] = cpu.INC_SP
+	cpu.opcodeTable[0x34] = cpu.INC_mHL
+	cpu.opcodeTable[0x35] = cpu.DEC_mHL
+	cpu.opcodeTable[0x36] = cpu.LD_HL_u8
+	cpu.opcodeTable[0x37] = cpu.SCF
+	cpu.opcodeTable[0x38] = cpu.JR_C_r8
+	cpu.opcodeTable[0x39] = cpu.ADD_HL_SP
+	cpu.opcodeTable[0x3A] = cpu.LD_A_HLd
+	cpu.opcodeTable[0x3B] = cpu.DEC_SP
+	cpu.opcodeTable[0x3C] = cpu.INC_A
+	cpu.opcodeTable[0x3D] = cpu.DEC_A
+	cpu.opcodeTable[0x3E] = cpu.LD_A_u8
+	cpu.opcodeTable[0x3F] = cpu.CCF
+	cpu.opcodeTable[0x40] = cpu.LD_B_B
+	cpu.opcodeTable[0x41] = cpu.LD_B_C
+	cpu.opcodeTable[0x42] = cpu.LD_B_D
+	cpu.opcodeTable[0x43] = cpu.LD_B_E
+	cpu.opcodeTable[0x44] = cpu.LD_B_H
+	cpu.opcodeTable[0x45] = cpu.LD_B_L
+	cpu.opcodeTable[0x46] = cpu.LD_B_HL
+	cpu.opcodeTable[0x47] = cpu.LD_B_A
+	cpu.opcodeTable[0x48] = cpu.LD_C_B
+	cpu.opcodeTable[0x49] = cpu.LD_C_C
+	cpu.opcodeTable[0x4A] = cpu.LD_C_D
+	cpu.opcodeTable[0x4B] = cpu.LD_C_E
+	cpu.opcodeTable[0x4C] = cpu.LD_C_H
+	cpu.opcodeTable[0x4D] = cpu.LD_C_L
+	cpu.opcodeTable[0x4E] = cpu.LD_C_HL
+	cpu.opcodeTable[0x4F] = cpu.LD_C_A
+	cpu.opcodeTable[0x50] = cpu.LD_D_B
+	cpu.opcodeTable[0x51] = cpu.LD_D_C
+	cpu.opcodeTable[0x52] = cpu.LD_D_D
+	cpu.opcodeTable[0x53] = cpu.LD_D_E
+	cpu.opcodeTable[0x54] = cpu.LD_D_H
+	cpu.opcodeTable[0x55] = cpu.LD_D_L
+	cpu.opcodeTable[0x56] = cpu.LD_D_HL
+	cpu.opcodeTable[0x57] = cpu.LD_D_A
+	cpu.opcodeTable[0x58] = cpu.LD_E_B
+	cpu.opcodeTable[0x59] = cpu.LD_E_C
+	cpu.opcodeTable[0x5A] = cpu.LD_E_D
+	cpu.opcodeTable[0x5B] = cpu.LD_E_E
+	cpu.opcodeTable[0x5C] = cpu.LD_E_H
+	cpu.opcodeTable[0x5D] = cpu.LD_E_L
+	cpu.opcodeTable[0x5E] = cpu.LD_E_HL
+	cpu.opcodeTable[0x5F] = cpu.LD_E_A
+	cpu.opcodeTable[0x60] = cpu.LD_H_B
+	cpu.opcodeTable[0x61] = cpu.LD_H_C
+	cpu.opcodeTable[0x62] = cpu.LD_H_D
+	cpu.opcodeTable[0x63] = cpu.LD_H_E
+	cpu.opcodeTable[0x64] = cpu.LD_H_H
+	cpu.opcodeTable[0x65] = cpu.LD_H_L
+	cpu.opcodeTable[0x66] = cpu.LD_H_HL
+	cpu.opcodeTable[0x67] = cpu.LD_H_A
+	cpu.opcodeTable[0x68] = cpu.LD_L_B
+	cpu.opcodeTable[0x69] = cpu.LD_L_C
+	cpu.opcodeTable[0x6A] = cpu.LD_L_D
+	cpu.opcodeTable[0x6B] = cpu.LD_L_E
+	cpu.opcodeTable[0x6C] = cpu.LD_L_H
+	cpu.opcodeTable[0x6D] = cpu.LD_L_L
+	cpu.opcodeTable[0x6E] = cpu.LD_L_HL
+	cpu.opcodeTable[0x6F] = cpu.LD_L_A
+	cpu.opcodeTable[0x70] = cpu.LD_HL_B
+	cpu.opcodeTable[0x71] = cpu.LD_HL_C
+	cpu.opcodeTable[0x72] = cpu.LD_HL_D
+	cpu.opcodeTable[0x73] = cpu.LD_HL_E
+	cpu.opcodeTable[0x74] = cpu.LD_HL_H
+	cpu.opcodeTable[0x75] = cpu.LD_HL_L
+	cpu.opcodeTable[0x76] = cpu.HALT
+	cpu.opcodeTable[0x77] = cpu.LD_HL_A
+	cpu.opcodeTable[0x78] = cpu.LD_A_B
+	cpu.opcodeTable[0x79] = cpu.LD_A_C
+	cpu.opcodeTable[0x7A] = cpu.LD_A_D
+	cpu.opcodeTable[0x7B] = cpu.LD_A_E
+	cpu.opcodeTable[0x7C] = cpu.LD_A_H
+	cpu.opcodeTable[0x7D] = cpu.LD_A_L
+	cpu.opcodeTable[0x7E] = cpu.LD_A_HL
+	cpu.opcodeTable[0x7F] = cpu.LD_A_A
+	cpu.opcodeTable[0x80] = cpu.ADD_A_B
+	cpu.opcodeTable[0x81] = cpu.ADD_A_C
+	cpu.opcodeTable[0x82] = cpu.ADD_A_D
+	cpu.opcodeTable[0x83] = cpu.ADD_A_E
+	cpu.opcodeTable[0x84] = cpu.ADD_A_H
+	cpu.opcodeTable[0x85] = cpu.ADD_A_L
+	cpu.opcodeTable[0x86] = cpu.ADD_A_HL
+	cpu.opcodeTable[0x87] = cpu.ADD_A_A
+	cpu.opcodeTable[0x88] = cpu.ADC_A_B
+	cpu.opcodeTable[0x89] = cpu.ADC_A_C
+	cpu.opcodeTable[0x8A] = cpu.ADC_A_D
+	cpu.opcodeTable[0x8B] = cpu.ADC_A_E
+	cpu.opcodeTable[0x8C] = cpu.ADC_A_H
+	cpu.opcodeTable[0x8D] = cpu.ADC_A_L
+	cpu.opcodeTable[0x8E] = cpu.ADC_A_HL
+	cpu.opcodeTable[0x8F] = cpu.ADC_A_A
+	cpu.opcodeTable[0x90] = cpu.SUB_A_B
+	cpu.opcodeTable[0x91] = cpu.SUB_A_C
+	cpu.opcodeTable[0x92] = cpu.SUB_A_D
+	cpu.opcodeTable[0x93] = cpu.SUB_A_E
+	cpu.opcodeTable[0x94] = cpu.SUB_A_H
+	cpu.opcodeTable[0x95] = cpu.SUB_A_L
+	cpu.opcodeTable[0x96] = cpu.SUB_A_HL
+	cpu.opcodeTable[0x97] = cpu.SUB_A_A
+	cpu.opcodeTable[0x98] = cpu.SBC_A_B
+	cpu.opcodeTable[0x99] = cpu.SBC_A_C
+	cpu.opcodeTable[0x9A] = cpu.SBC_A_D
+	cpu.opcodeTable[0x9B] = cpu.SBC_A_E
+	cpu.opcodeTable[0x9C] = cpu.SBC_A_H
+	cpu.opcodeTable[0x9D] = cpu.SBC_A_L
+	cpu.opcodeTable[0x9E] = cpu.SBC_A_HL
+	cpu.opcodeTable[0x9F] = cpu.SBC_A_A
+	cpu.opcodeTable[0xA0] = cpu.AND_A_B
+	cpu.opcodeTable[0xA1] = cpu.AND_A_C
+	cpu.opcodeTable[0xA2] = cpu.AND_A_D
+	cpu.opcodeTable[0xA3] = cpu.AND_A_E
+	cpu.opcodeTable[0xA4] = cpu.AND_A_H
+	cpu.opcodeTable[0xA5] = cpu.AND_A_L
+	cpu.opcodeTable[0xA6] = cpu.AND_A_HL
+	cpu.opcodeTable[0xA7] = cpu.AND_A_A
+	cpu.opcodeTable[0xA8] = cpu.XOR_A_B
+	cpu.opcodeTable[0xA9] = cpu.XOR_A_C
+	cpu.opcodeTable[0xAA] = cpu.XOR_A_D
+	cpu.opcodeTable[0xAB] = cpu.XOR_A_E
+	cpu.opcodeTable[0xAC] = cpu.XOR_A_H
+	cpu.opcodeTable[0xAD] = cpu.XOR_A_L
+	cpu.opcodeTable[0xAE] = cpu.XOR_A_HL
+	cpu.opcodeTable[0xAF] = cpu.XOR_A_A
+	cpu.opcodeTable[0xB0] = cpu.OR_A_B
+	cpu.opcodeTable[0xB1] = cpu.OR_A_C
+	cpu.opcodeTable[0xB2] = cpu.OR_A_D
+	cpu.opcodeTable[0xB3] = cpu.OR_A_E
+	cpu.opcodeTable[0xB4] = cpu.OR_A_H
+	cpu.opcodeTable[0xB5] = cpu.OR_A_L
+	cpu.opcodeTable[0xB6] = cpu.OR_A_HL
+	cpu.opcodeTable[0xB7] = cpu.OR_A_A
+	cpu.opcodeTable[0xB8] = cpu.CP_A_B
+	cpu.opcodeTable[0xB9] = cpu.CP_A_C
+	cpu.opcodeTable[0xBA] = cpu.CP_A_D
+	cpu.opcodeTable[0xBB] = cpu.CP_A_E
+	cpu.opcodeTable[0xBC] = cpu.CP_A_H
+	cpu.opcodeTable[0xBD] = cpu.CP_A_L
+	cpu.opcodeTable[0xBE] = cpu.CP_A_HL
+	cpu.opcodeTable[0xBF] = cpu.CP_A_A
+	cpu.opcodeTable[0xC0] = cpu.RET_NZ
+	cpu.opcodeTable[0xC1] = cpu.POP_BC
+	cpu.opcodeTable[0xC2] = cpu.JP_NZ_u16
+	cpu.opcodeTable[0xC3] = cpu.JP_u16
+	cpu.opcodeTable[0xC4] = cpu.CALL_NZ_u16
+	cpu.opcodeTable[0xC5] = cpu.PUSH_BC
+	cpu.opcodeTable[0xC6] = cpu.ADD_A_u8
+	cpu.opcodeTable[0xC7] = cpu.RST_00H
+	cpu.opcodeTable[0xC8] = cpu.RET_Z
+	cpu.opcodeTable[0xC9] = cpu.RET
+	cpu.opcodeTable[0xCA] = cpu.JP_Z_u16
+	cpu.opcodeTable[0xCB] = cpu.ExecuteCBOpcode
+	cpu.opcodeTable[0xCC] = cpu.CALL_Z_u16
+	cpu.opcodeTable[0xCD] = cpu.CALL_u16
+	cpu.opcodeTable[0xCE] = cpu.ADC_A_u8
+	cpu.opcodeTable[0xCF] = cpu.RST_08H
+	cpu.opcodeTable[0xD0] = cpu.RET_NC
+	cpu.opcodeTable[0xD1] = cpu.POP_DE
+	cpu.opcodeTable[0xD2] = cpu.JP_NC_u16
+	cpu.opcodeTable[0xD4] = cpu.CALL_NC_u16
+	cpu.opcodeTable[0xD5] = cpu.PUSH_DE
+	cpu.opcodeTable[0xD6] = cpu.SUB_A_u8
+	cpu.opcodeTable[0xD7] = cpu.RST_10H
+	cpu.opcodeTable[0xD8] = cpu.RET_C
+	cpu.opcodeTable[0xD9] = cpu.RETI
+	cpu.opcodeTable[0xDA] = cpu.JP_C_u16
+	cpu.opcodeTable[0xDC] = cpu.CALL_C_u16
+	cpu.opcodeTable[0xDE] = cpu.SBC_A_u8
+	cpu.opcodeTable[0xDF] = cpu.RST_18H
+	cpu.opcodeTable[0xE0] = cpu.LD_u8C_A
+	cpu.opcodeTable[0xE1] = cpu.POP_HL
+	cpu.opcodeTable[0xE2] = cpu.LD_FFC_A
+	cpu.opcodeTable[0xE5] = cpu.PUSH_HL
+	cpu.opcodeTable[0xE6] = cpu.AND_A_u8
+	cpu.opcodeTable[0xE7] = cpu.RST_20H
+	cpu.opcodeTable[0xE8] = cpu.ADD_SP_r8
+	cpu.opcodeTable[0xE9] = cpu.JP_HL
+	cpu.opcodeTable[0xEA] = cpu.LD_u16_A
+	cpu.opcodeTable[0xEE] = cpu.XOR_A_u8
+	cpu.opcodeTable[0xEF] = cpu.RST_28H
+	cpu.opcodeTable[0xF0] = cpu.LD_A_u8C
+	cpu.opcodeTable[0xF1] = cpu.POP_AF
+	cpu.opcodeTable[0xF2] = cpu.LD_A_FFC
+	cpu.opcodeTable[0xF3] = cpu.DI
+	cpu.opcodeTable[0xF5] = cpu.PUSH_AF
+	cpu.opcodeTable[0xF6] = cpu.OR_A_u8
+	cpu.opcodeTable[0xF7] = cpu.RST_30H
+	cpu.opcodeTable[0xF8] = cpu.LD_HL_SPr8
+	cpu.opcodeTable[0xF9] = cpu.LD_SP_HL
+	cpu.opcodeTable[0xFA] = cpu.LD_A_u16
+	cpu.opcodeTable[0xFB] = cpu.EI
+	cpu.opcodeTable[0xFE] = cpu.CP_A_u8
+	cpu.opcodeTable[0xFF] = cpu.RST_38H
+
+	for _, opcode := range illegalOpcodes {
+		cpu.opcodeTable[opcode] = cpu.illegalOpcode
+	}
+}
+
+func (cpu *CPU) initOpcodeCBTable() {
+	// CB opcodes are fully regular: bits 0-2 select the operand
+	// (B, C, D, E, H, L, (HL), A), bits 3-5 select the shift operation
+	// or bit number, and bits 6-7 select the instruction group.
+	shifts := [8]func(uint8) uint8{cpu.rlc, cpu.rrc, cpu.rl, cpu.rr, cpu.sla, cpu.sra, cpu.swap, cpu.srl}
+
+	for opcode := 0; opcode < 256; opcode++ {
+		reg := uint8(opcode & 0x07)
+		bit := uint8(opcode>>3) & 0x07
+
+		// Cycle counts include the 0xCB prefix fetch. (HL) operands need
+		// an extra read, and a write back for everything except BIT.
+		cycles, bitCycles := 2, 2
+		if reg == 6 {
+			cycles, bitCycles = 4, 3
+		}
+
+		switch opcode >> 6 {
+		case 0:
+			// 0x00-0x3F: RLC, RRC, RL, RR, SLA, SRA, SWAP, SRL
+			shift := shifts[bit]
+			cpu.opcodeCBTable[opcode] = func() int {
+				cpu.writeR8(reg, shift(cpu.readR8(reg)))
+				return cycles
+			}
+		case 1:
+			// 0x40-0x7F: Test bit, set Z if it is clear
+			cpu.opcodeCBTable[opcode] = func() int {
+				cpu.SetZeroFlag(cpu.readR8(reg)&(1<<bit) == 0)
+				cpu.SetSubtractFlag(false)
+				cpu.SetHalfCarryFlag(true)
+				return bitCycles
+			}
+		case 2:
+			// 0x80-0xBF: Reset bit
+			cpu.opcodeCBTable[opcode] = func() int {
+				cpu.writeR8(reg, cpu.readR8(reg)&^(1<<bit))
+				return cycles
+			}
+		case 3:
+			// 0xC0-0xFF: Set bit
+			cpu.opcodeCBTable[opcode] = func() int {
+				cpu.writeR8(reg, cpu.readR8(reg)|(1<<bit))
+				return cycles
+			}
+		}
+	}
+}
+
+func (cpu *CPU) illegalOpcode() int {
+	// 0xD3, 0xDB, 0xDD, 0xE3, 0xE4, 0xEB-0xED, 0xF4, 0xFC, 0xFD: Lock up the CPU
+	cpu.fault = &IllegalOpcodeError{Opcode: cpu.memory.Read(cpu.PC), PC: cpu.PC}
+	return 1
+}
+
+func (cpu *CPU) NOP() int {
 	// 0x00: Do nothing, increment PC
 	cpu.PC++
+	return 1
 }
 
-func (cpu *CPU) LD_BC_u16() {
+func (cpu *CPU) LD_BC_u16() int {
 	// 0x01: Load next 2 bytes to BC
+	cpu.SetBC(cpu.fetchU16())
 	cpu.PC++
-	low := cpu.memory.Read(cpu.PC)
-	cpu.PC++
-	high := cpu.memory.Read(cpu.PC)
-	value := uint16(high)<<8 | uint16(low)
+	return 3
+}
 
-	cpu.B = uint8(value >> 8)
-	cpu.C = uint8(value & 0xFF)
+func (cpu *CPU) LD_BC_A() int {
+	// 0x02: Store the value in register A at memory location BC
+	cpu.memory.Write(cpu.BC(), cpu.A)
 	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) INC_BC() {
+func (cpu *CPU) INC_BC() int {
 	// 0x03: Increment BC
-	if cpu.C == 0xFF {
-		cpu.C = 0x00
-		cpu.B++
-	} else {
-		cpu.C++
-	}
+	cpu.SetBC(cpu.BC() + 1)
 	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) RLCA() {
-	// 0x07: Rotate the value of register A left by one bit
-	carry := (cpu.A >> 7) & 1
-	cpu.A = (cpu.A << 1) | carry
-	cpu.SetCarryFlag(carry == 1)
+func (cpu *CPU) INC_B() int {
+	// 0x04: Increment register B
+	cpu.B = cpu.inc8(cpu.B)
 	cpu.PC++
+	return 1
 }
 
-func (cpu *CPU) RRCA() {
-	// 0x0F: Rotate the value of register A right one bit
-	carry := cpu.A & 0x01
-	cpu.A = (cpu.A >> 1) | (carry << 7)
+func (cpu *CPU) DEC_B() int {
+	// 0x05: Decrement register B by 1
+	cpu.B = cpu.dec8(cpu.B)
+	cpu.PC++
+	return 1
+}
 
-	cpu.SetCarryFlag(carry == 1)
+func (cpu *CPU) LD_B_u8() int {
+	// 0x06: Load next byte into register B
+	cpu.B = cpu.fetchU8()
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) RLCA() int {
+	// 0x07: Rotate the value of register A left by one bit
+	cpu.A = cpu.rlc(cpu.A)
 	cpu.SetZeroFlag(false)
-	cpu.SetHalfCarryFlag(false)
-	cpu.SetSubtractFlag(false)
+	cpu.PC++
+	return 1
+}
 
+func (cpu *CPU) LD_u16_SP() int {
+	// 0x08: Store SP at the memory location of the next two bytes
+	address := cpu.fetchU16()
+	cpu.memory.Write(address, uint8(cpu.SP&0xFF))
+	cpu.memory.Write(address+1, uint8(cpu.SP>>8))
 	cpu.PC++
+	return 5
 }
 
-func (cpu *CPU) LD_DE_u16() {
-	// 0x11: Load next 2 bytes to DE
+func (cpu *CPU) ADD_HL_BC() int {
+	// 0x09: HL = HL + BC
+	cpu.addHL(cpu.BC())
 	cpu.PC++
-	low := cpu.memory.Read(cpu.PC)
+	return 2
+}
+
+func (cpu *CPU) LD_A_BC() int {
+	// 0x0A: Load the value at memory location BC into register A
+	cpu.A = cpu.memory.Read(cpu.BC())
 	cpu.PC++
-	high := cpu.memory.Read(cpu.PC)
-	value := uint16(high)<<8 | uint16(low)
+	return 2
+}
 
-	cpu.D = uint8(value >> 8)
-	cpu.E = uint8(value & 0xFF)
+func (cpu *CPU) DEC_BC() int {
+	// 0x0B: Decrement BC
+	cpu.SetBC(cpu.BC() - 1)
 	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) INC_DE() {
-	// 0x13: Increment DE
-	if cpu.E == 0xFF {
-		cpu.E = 0x00
-		cpu.D++
-	} else {
-		cpu.E++
-	}
+func (cpu *CPU) INC_C() int {
+	// 0x0C: Increment register C
+	cpu.C = cpu.inc8(cpu.C)
 	cpu.PC++
+	return 1
 }
 
-func (cpu *CPU) RRA() {
-	// 0x1F: Rotate register A right
-	carry := cpu.A & 0x01
-	cpu.A = (cpu.A >> 1) | (boolToUint8(cpu.GetCarryFlag()) << 7)
+func (cpu *CPU) DEC_C() int {
+	// 0x0D: Decrement register C by 1
+	cpu.C = cpu.dec8(cpu.C)
+	cpu.PC++
+	return 1
+}
 
-	cpu.SetCarryFlag(carry == 1)
-	cpu.SetZeroFlag(cpu.A == 0)
-	cpu.SetSubtractFlag(false)
-	cpu.SetHalfCarryFlag(false)
+func (cpu *CPU) LD_C_u8() int {
+	// 0x0E: Load next byte into register C
+	cpu.C = cpu.fetchU8()
+	cpu.PC++
+	return 2
+}
 
+func (cpu *CPU) RRCA() int {
+	// 0x0F: Rotate the value of register A right one bit
+	cpu.A = cpu.rrc(cpu.A)
+	cpu.SetZeroFlag(false)
 	cpu.PC++
+	return 1
 }
 
-func (cpu *CPU) JR_NZ_r8() {
-	// 0x20: If Z flag == 0, jump to PC + r8
-	offset := int8(cpu.memory.Read(cpu.PC + 1))
+func (cpu *CPU) STOP() int {
+	// 0x10: Stop the CPU until a button is pressed, or switch speed on CGB
+	cpu.fetchU8()
+	cpu.PC++
+	cpu.memory.ResetDIV()
 
-	if !cpu.GetZeroFlag() {
-		cpu.PC += uint16(offset) + 2
-	} else {
-		cpu.PC += 2
+	if cpu.memory.SpeedSwitchArmed() {
+		// The CPU resumes on its own once the clock has settled
+		cpu.memory.SwitchSpeed()
+		return speedSwitchCycles
 	}
+
+	cpu.stopped = true
+	return 1
 }
 
-func (cpu *CPU) LD_HL_u16() {
-	// 0x21: Load next 2 bytes to HL
-	cpu.PC++
-	low := cpu.memory.Read(cpu.PC)
+func (cpu *CPU) LD_DE_u16() int {
+	// 0x11: Load next 2 bytes to DE
+	cpu.SetDE(cpu.fetchU16())
 	cpu.PC++
-	high := cpu.memory.Read(cpu.PC)
-	value := uint16(high)<<8 | uint16(low)
+	return 3
+}
 
-	cpu.H = uint8(value >> 8)
-	cpu.L = uint8(value & 0xFF)
+func (cpu *CPU) LD_DE_A() int {
+	// 0x12: Store the value in register A at memory location DE
+	cpu.memory.Write(cpu.DE(), cpu.A)
 	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) LD_HLi_A() {
-	// 0x22: Store the value in register A at memory location HL, then increment HL
-	cpu.memory.Write(cpu.HL(), cpu.A)
-	cpu.SetHL(cpu.HL() + 1)
+func (cpu *CPU) INC_DE() int {
+	// 0x13: Increment DE
+	cpu.SetDE(cpu.DE() + 1)
 	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) DEC_H() {
-	// 0x25: Decrement register H by 1
-	cpu.H--
-
-	cpu.SetSubtractFlag(true)
-	cpu.SetZeroFlag(cpu.H == 0)
-	cpu.SetHalfCarryFlag((cpu.H & 0x0F) == 0x0F)
-
+func (cpu *CPU) INC_D() int {
+	// 0x14: Increment register D
+	cpu.D = cpu.inc8(cpu.D)
 	cpu.PC++
+	return 1
 }
 
-func (cpu *CPU) LD_H_u8() {
-	// 0x26: Load next byte into register H
-	cpu.PC++
-	cpu.H = cpu.memory.Read(cpu.PC)
+func (cpu *CPU) DEC_D() int {
+	// 0x15: Decrement register D by 1
+	cpu.D = cpu.dec8(cpu.D)
 	cpu.PC++
+	return 1
 }
 
-func (cpu *CPU) INC_L() {
-	// 0x2C: Increment register L
-	oldL := cpu.L
-	cpu.L++
-
-	cpu.SetZeroFlag(cpu.L == 0)
-	cpu.SetSubtractFlag(false)
-	cpu.SetHalfCarryFlag((oldL&0x0F)+1 > 0x0F)
-
+func (cpu *CPU) LD_D_u8() int {
+	// 0x16: Load next byte into register D
+	cpu.D = cpu.fetchU8()
 	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) DEC_L() {
-	// 0x2D: Decrement register L by 1
-	cpu.L--
-
-	cpu.SetSubtractFlag(true)
-	cpu.SetZeroFlag(cpu.L == 0)
-	cpu.SetHalfCarryFlag((cpu.L & 0x0F) == 0x0F)
-
+func (cpu *CPU) RLA() int {
+	// 0x17: Rotate register A left through the carry flag
+	cpu.A = cpu.rl(cpu.A)
+	cpu.SetZeroFlag(false)
 	cpu.PC++
+	return 1
 }
 
-func (cpu *CPU) CPL() {
-	// 0x2F: Complement A register
-	cpu.A = ^cpu.A
-
-	cpu.SetSubtractFlag(true)
-	cpu.SetHalfCarryFlag(false)
+func (cpu *CPU) JR_r8() int {
+	// 0x18: Jump to PC + r8
+	return cpu.jr(true)
+}
 
+func (cpu *CPU) ADD_HL_DE() int {
+	// 0x19: HL = HL + DE
+	cpu.addHL(cpu.DE())
 	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) JR_NC_r8() {
-	// 0x30: If C flag == 0, jump to PC + r8
-	offset := int8(cpu.memory.Read(cpu.PC + 1))
+func (cpu *CPU) LD_A_DE() int {
+	// 0x1A: Load the value at memory location DE into register A
+	cpu.A = cpu.memory.Read(cpu.DE())
+	cpu.PC++
+	return 2
+}
 
-	if !cpu.GetCarryFlag() {
-		cpu.PC += uint16(offset) + 2
-	} else {
-		cpu.PC += 2
-	}
+func (cpu *CPU) DEC_DE() int {
+	// 0x1B: Decrement DE
+	cpu.SetDE(cpu.DE() - 1)
+	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) LD_SP_u16() {
-	// 0x31: Set SP to next two bytes in program
+func (cpu *CPU) INC_E() int {
+	// 0x1C: Increment register E
+	cpu.E = cpu.inc8(cpu.E)
 	cpu.PC++
-	low := cpu.memory.Read(cpu.PC)
+	return 1
+}
+
+func (cpu *CPU) DEC_E() int {
+	// 0x1D: Decrement register E by 1
+	cpu.E = cpu.dec8(cpu.E)
 	cpu.PC++
-	high := cpu.memory.Read(cpu.PC)
-	address := (uint16(high)<<8 | uint16(low))
-	cpu.SP = address
+	return 1
+}
+
+func (cpu *CPU) LD_E_u8() int {
+	// 0x1E: Load next byte into register E
+	cpu.E = cpu.fetchU8()
 	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) INC_A() {
-	// 0x3C: Increment register A
-	oldA := cpu.A
-	cpu.A++
+func (cpu *CPU) RRA() int {
+	// 0x1F: Rotate register A right through the carry flag
+	cpu.A = cpu.rr(cpu.A)
+	cpu.SetZeroFlag(false)
+	cpu.PC++
+	return 1
+}
 
-	cpu.SetZeroFlag(cpu.A == 0)
-	cpu.SetSubtractFlag(false)
-	cpu.SetHalfCarryFlag((oldA&0x0F)+1 > 0x0F)
+func (cpu *CPU) JR_NZ_r8() int {
+	// 0x20: If Z flag == 0, jump to PC + r8
+	return cpu.jr(!cpu.GetZeroFlag())
+}
 
+func (cpu *CPU) LD_HL_u16() int {
+	// 0x21: Load next 2 bytes to HL
+	cpu.SetHL(cpu.fetchU16())
 	cpu.PC++
+	return 3
 }
 
-func (cpu *CPU) LD_A_u8() {
-	// 0x3E: Load next byte into register A
-	cpu.PC++
-	cpu.A = cpu.memory.Read(cpu.PC)
+func (cpu *CPU) LD_HLi_A() int {
+	// 0x22: Store the value in register A at memory location HL, then increment HL
+	cpu.memory.Write(cpu.HL(), cpu.A)
+	cpu.SetHL(cpu.HL() + 1)
 	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) LD_B_B() {
-	// 0x40: Load the contents of register B into register B (does nothing)
+func (cpu *CPU) INC_HL() int {
+	// 0x23: Increment HL
+	cpu.SetHL(cpu.HL() + 1)
 	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) LD_B_HL() {
-	// 0x46: Load the value at memory location HL into register B
-	cpu.B = cpu.memory.Read(cpu.HL())
+func (cpu *CPU) INC_H() int {
+	// 0x24: Increment register H
+	cpu.H = cpu.inc8(cpu.H)
+	cpu.PC++
+	return 1
+}
 
+func (cpu *CPU) DEC_H() int {
+	// 0x25: Decrement register H by 1
+	cpu.H = cpu.dec8(cpu.H)
 	cpu.PC++
+	return 1
 }
 
-func (cpu *CPU) LD_B_A() {
-	// 0x47: Load the contents of register A into register B
-	cpu.B = cpu.A
+func (cpu *CPU) LD_H_u8() int {
+	// 0x26: Load next byte into register H
+	cpu.H = cpu.fetchU8()
 	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) LD_C_HL() {
-	// 0x4E: Load the value at memory location HL into register C
-	cpu.C = cpu.memory.Read(cpu.HL())
+func (cpu *CPU) DAA() int {
+	// 0x27: Decimal adjust register A after a BCD addition or subtraction
+	adjust := uint8(0)
+	carry := cpu.GetCarryFlag()
+
+	if cpu.GetHalfCarryFlag() || (!cpu.GetSubtractFlag() && cpu.A&0x0F > 0x09) {
+		adjust |= 0x06
+	}
+	if carry || (!cpu.GetSubtractFlag() && cpu.A > 0x99) {
+		adjust |= 0x60
+		carry = true
+	}
+
+	if cpu.GetSubtractFlag() {
+		cpu.A -= adjust
+	} else {
+		cpu.A += adjust
+	}
+
+	cpu.SetZeroFlag(cpu.A == 0)
+	cpu.SetHalfCarryFlag(false)
+	cpu.SetCarryFlag(carry)
 
 	cpu.PC++
+	return 1
 }
 
-func (cpu *CPU) LD_C_A() {
-	// 0x4F: Load the contents of register A into register C
-	cpu.C = cpu.A
-	cpu.PC++
+func (cpu *CPU) JR_Z_r8() int {
+	// 0x28: If Z flag == 1, jump to PC + r8
+	return cpu.jr(cpu.GetZeroFlag())
 }
 
-func (cpu *CPU) LD_D_L() {
-	// 0x55: Load the contents of register L into register D
-	cpu.D = cpu.L
+func (cpu *CPU) ADD_HL_HL() int {
+	// 0x29: HL = HL + HL
+	cpu.addHL(cpu.HL())
 	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) LD_D_HL() {
-	// 0x56: Load the value at memory location HL into register D
-	cpu.D = cpu.memory.Read(cpu.HL())
-
+func (cpu *CPU) LD_A_HLi() int {
+	// 0x2A: Load the value at memory location HL into register A, then increment HL
+	cpu.A = cpu.memory.Read(cpu.HL())
+	cpu.SetHL(cpu.HL() + 1)
 	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) LD_D_A() {
-	// 0x57: Load the contents of register A into register D
-	cpu.D = cpu.A
+func (cpu *CPU) DEC_HL() int {
+	// 0x2B: Decrement HL
+	cpu.SetHL(cpu.HL() - 1)
 	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) LD_E_A() {
-	// 0x5F: Load the contents of register A into register E
-	cpu.E = cpu.A
+func (cpu *CPU) INC_L() int {
+	// 0x2C: Increment register L
+	cpu.L = cpu.inc8(cpu.L)
 	cpu.PC++
+	return 1
 }
 
-func (cpu *CPU) LD_H_A() {
-	// 0x67: Load the contents of register A into register H
-	cpu.H = cpu.A
+func (cpu *CPU) DEC_L() int {
+	// 0x2D: Decrement register L by 1
+	cpu.L = cpu.dec8(cpu.L)
 	cpu.PC++
+	return 1
 }
 
-func (cpu *CPU) LD_L_A() {
-	// 0x6F: Load the contents of register A into register L
-	cpu.L = cpu.A
+func (cpu *CPU) LD_L_u8() int {
+	// 0x2E: Load next byte into register L
+	cpu.L = cpu.fetchU8()
 	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) LD_HL_B() {
-	// 0x70: Store the value in register B at memory location HL
-	cpu.memory.Write(cpu.HL(), cpu.B)
+func (cpu *CPU) CPL() int {
+	// 0x2F: Complement A register
+	cpu.A = ^cpu.A
+
+	cpu.SetSubtractFlag(true)
+	cpu.SetHalfCarryFlag(true)
+
 	cpu.PC++
+	return 1
 }
 
-func (cpu *CPU) LD_HL_C() {
-	// 0x71: Store the value in register C at memory location HL
-	cpu.memory.Write(cpu.HL(), cpu.C)
-	cpu.PC++
+func (cpu *CPU) JR_NC_r8() int {
+	// 0x30: If C flag == 0, jump to PC + r8
+	return cpu.jr(!cpu.GetCarryFlag())
 }
 
-func (cpu *CPU) LD_HL_D() {
-	// 0x72: Store the value in register D at memory location HL
-	cpu.memory.Write(cpu.HL(), cpu.D)
+func (cpu *CPU) LD_SP_u16() int {
+	// 0x31: Set SP to next two bytes in program
+	cpu.SP = cpu.fetchU16()
 	cpu.PC++
+	return 3
 }
 
-func (cpu *CPU) LD_A_B() {
-	// 0x78: Load the contents of register B into register A
-	cpu.A = cpu.B
+func (cpu *CPU) LD_HLd_A() int {
+	// 0x32: Store the value in register A at memory location HL, then decrement HL
+	cpu.memory.Write(cpu.HL(), cpu.A)
+	cpu.SetHL(cpu.HL() - 1)
 	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) LD_A_C() {
-	// 0x79: Load the contents of register C into register A
-	cpu.A = cpu.C
+func (cpu *CPU) INC_SP() int {
+	// 0x33: Increment SP
+	cpu.SP++
 	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) LD_A_D() {
-	// 0x7A: Load the contents of register D into register A
-	cpu.A = cpu.D
+func (cpu *CPU) INC_mHL() int {
+	// 0x34: Increment the value at memory location HL
+	cpu.memory.Write(cpu.HL(), cpu.inc8(cpu.memory.Read(cpu.HL())))
 	cpu.PC++
+	return 3
 }
 
-func (cpu *CPU) LD_A_E() {
-	// 0x7B: Load the contents of register E into register A
-	cpu.A = cpu.E
+func (cpu *CPU) DEC_mHL() int {
+	// 0x35: Decrement the value at memory location HL
+	cpu.memory.Write(cpu.HL(), cpu.dec8(cpu.memory.Read(cpu.HL())))
 	cpu.PC++
+	return 3
 }
 
-func (cpu *CPU) ADD_A_B() {
-	// 0x80: A = A + B
-	result := uint16(cpu.A) + uint16(cpu.B)
+func (cpu *CPU) LD_HL_u8() int {
+	// 0x36: Store the next byte at memory location HL
+	cpu.memory.Write(cpu.HL(), cpu.fetchU8())
+	cpu.PC++
+	return 3
+}
 
-	cpu.SetZeroFlag(uint8(result) == 0)
+func (cpu *CPU) SCF() int {
+	// 0x37: Set the carry flag
 	cpu.SetSubtractFlag(false)
-	cpu.SetHalfCarryFlag((cpu.A&0x0F)+(cpu.B&0x0F) > 0x0F)
-	cpu.SetCarryFlag(result > 0xFF)
-
-	cpu.A = uint8(result)
+	cpu.SetHalfCarryFlag(false)
+	cpu.SetCarryFlag(true)
 	cpu.PC++
+	return 1
 }
 
-func (cpu *CPU) ADD_A_C() {
-	// 0x81: A = A + C
-	result := uint16(cpu.A) + uint16(cpu.C)
+func (cpu *CPU) JR_C_r8() int {
+	// 0x38: If C flag == 1, jump to PC + r8
+	return cpu.jr(cpu.GetCarryFlag())
+}
 
-	cpu.SetZeroFlag(uint8(result) == 0)
-	cpu.SetSubtractFlag(false)
-	cpu.SetHalfCarryFlag((cpu.A&0x0F)+(cpu.C&0x0F) > 0x0F)
-	cpu.SetCarryFlag(result > 0xFF)
+func (cpu *CPU) ADD_HL_SP() int {
+	// 0x39: HL = HL + SP
+	cpu.addHL(cpu.SP)
+	cpu.PC++
+	return 2
+}
 
-	cpu.A = uint8(result)
+func (cpu *CPU) LD_A_HLd() int {
+	// 0x3A: Load the value at memory location HL into register A, then decrement HL
+	cpu.A = cpu.memory.Read(cpu.HL())
+	cpu.SetHL(cpu.HL() - 1)
 	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) ADD_A_D() {
-	// 0x82: A = A + D
-	result := uint16(cpu.A) + uint16(cpu.D)
+func (cpu *CPU) DEC_SP() int {
+	// 0x3B: Decrement SP
+	cpu.SP--
+	cpu.PC++
+	return 2
+}
 
-	cpu.SetZeroFlag(uint8(result) == 0)
-	cpu.SetSubtractFlag(false)
-	cpu.SetHalfCarryFlag((cpu.A&0x0F)+(cpu.D&0x0F) > 0x0F)
-	cpu.SetCarryFlag(result > 0xFF)
+func (cpu *CPU) INC_A() int {
+	// 0x3C: Increment register A
+	cpu.A = cpu.inc8(cpu.A)
+	cpu.PC++
+	return 1
+}
 
-	cpu.A = uint8(result)
+func (cpu *CPU) DEC_A() int {
+	// 0x3D: Decrement register A by 1
+	cpu.A = cpu.dec8(cpu.A)
 	cpu.PC++
+	return 1
 }
 
-func (cpu *CPU) ADD_A_E() {
-	// 0x83: A = A + E
-	result := uint16(cpu.A) + uint16(cpu.E)
+func (cpu *CPU) LD_A_u8() int {
+	// 0x3E: Load next byte into register A
+	cpu.A = cpu.fetchU8()
+	cpu.PC++
+	return 2
+}
 
-	cpu.SetZeroFlag(uint8(result) == 0)
+func (cpu *CPU) CCF() int {
+	// 0x3F: Complement the carry flag
 	cpu.SetSubtractFlag(false)
-	cpu.SetHalfCarryFlag((cpu.A&0x0F)+(cpu.E&0x0F) > 0x0F)
-	cpu.SetCarryFlag(result > 0xFF)
-
-	cpu.A = uint8(result)
+	cpu.SetHalfCarryFlag(false)
+	cpu.SetCarryFlag(!cpu.GetCarryFlag())
 	cpu.PC++
+	return 1
 }
 
-func (cpu *CPU) XOR_A_HL() {
-	// 0xAE: Perform XOR operation with value from memory location stored in HL on register A
-	cpu.A ^= cpu.memory.Read(cpu.HL())
+func (cpu *CPU) LD_B_B() int {
+	// 0x40: Load the contents of register B into register B (does nothing)
+	cpu.PC++
+	return 1
+}
 
-	cpu.SetZeroFlag(cpu.A == 0)
-	cpu.SetCarryFlag(false)
-	cpu.SetHalfCarryFlag(false)
-	cpu.SetSubtractFlag(false)
+func (cpu *CPU) LD_B_C() int {
+	// 0x41: Load the contents of register C into register B
+	cpu.B = cpu.C
+	cpu.PC++
+	return 1
+}
 
+func (cpu *CPU) LD_B_D() int {
+	// 0x42: Load the contents of register D into register B
+	cpu.B = cpu.D
 	cpu.PC++
+	return 1
 }
 
-func (cpu *CPU) CP_A_C() {
-	// 0xB9: Compare A and C, does not modify registers, only flags
-	result := int16(cpu.A) - int16(cpu.C)
+func (cpu *CPU) LD_B_E() int {
+	// 0x43: Load the contents of register E into register B
+	cpu.B = cpu.E
+	cpu.PC++
+	return 1
+}
 
-	cpu.SetZeroFlag(uint8(result) == 0)
-	cpu.SetSubtractFlag(true)
-	cpu.SetHalfCarryFlag((cpu.A & 0x0F) < (cpu.C & 0x0F))
-	cpu.SetCarryFlag(cpu.A < cpu.C)
+func (cpu *CPU) LD_B_H() int {
+	// 0x44: Load the contents of register H into register B
+	cpu.B = cpu.H
+	cpu.PC++
+	return 1
+}
 
+func (cpu *CPU) LD_B_L() int {
+	// 0x45: Load the contents of register L into register B
+	cpu.B = cpu.L
 	cpu.PC++
+	return 1
 }
 
-func (cpu *CPU) POP_BC() {
-	// 0xC1: Pop two bytes from the stack into register BC
-	low := cpu.memory.Read(cpu.SP)
-	cpu.SP++
-	high := cpu.memory.Read(cpu.SP)
-	cpu.SP++
+func (cpu *CPU) LD_B_HL() int {
+	// 0x46: Load the value at memory location HL into register B
+	cpu.B = cpu.memory.Read(cpu.HL())
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) LD_B_A() int {
+	// 0x47: Load the contents of register A into register B
+	cpu.B = cpu.A
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_C_B() int {
+	// 0x48: Load the contents of register B into register C
+	cpu.C = cpu.B
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_C_C() int {
+	// 0x49: Load the contents of register C into register C (does nothing)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_C_D() int {
+	// 0x4A: Load the contents of register D into register C
+	cpu.C = cpu.D
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_C_E() int {
+	// 0x4B: Load the contents of register E into register C
+	cpu.C = cpu.E
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_C_H() int {
+	// 0x4C: Load the contents of register H into register C
+	cpu.C = cpu.H
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_C_L() int {
+	// 0x4D: Load the contents of register L into register C
+	cpu.C = cpu.L
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_C_HL() int {
+	// 0x4E: Load the value at memory location HL into register C
+	cpu.C = cpu.memory.Read(cpu.HL())
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) LD_C_A() int {
+	// 0x4F: Load the contents of register A into register C
+	cpu.C = cpu.A
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_D_B() int {
+	// 0x50: Load the contents of register B into register D
+	cpu.D = cpu.B
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_D_C() int {
+	// 0x51: Load the contents of register C into register D
+	cpu.D = cpu.C
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_D_D() int {
+	// 0x52: Load the contents of register D into register D (does nothing)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_D_E() int {
+	// 0x53: Load the contents of register E into register D
+	cpu.D = cpu.E
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_D_H() int {
+	// 0x54: Load the contents of register H into register D
+	cpu.D = cpu.H
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_D_L() int {
+	// 0x55: Load the contents of register L into register D
+	cpu.D = cpu.L
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_D_HL() int {
+	// 0x56: Load the value at memory location HL into register D
+	cpu.D = cpu.memory.Read(cpu.HL())
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) LD_D_A() int {
+	// 0x57: Load the contents of register A into register D
+	cpu.D = cpu.A
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_E_B() int {
+	// 0x58: Load the contents of register B into register E
+	cpu.E = cpu.B
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_E_C() int {
+	// 0x59: Load the contents of register C into register E
+	cpu.E = cpu.C
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_E_D() int {
+	// 0x5A: Load the contents of register D into register E
+	cpu.E = cpu.D
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_E_E() int {
+	// 0x5B: Load the contents of register E into register E (does nothing)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_E_H() int {
+	// 0x5C: Load the contents of register H into register E
+	cpu.E = cpu.H
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_E_L() int {
+	// 0x5D: Load the contents of register L into register E
+	cpu.E = cpu.L
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_E_HL() int {
+	// 0x5E: Load the value at memory location HL into register E
+	cpu.E = cpu.memory.Read(cpu.HL())
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) LD_E_A() int {
+	// 0x5F: Load the contents of register A into register E
+	cpu.E = cpu.A
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_H_B() int {
+	// 0x60: Load the contents of register B into register H
+	cpu.H = cpu.B
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_H_C() int {
+	// 0x61: Load the contents of register C into register H
+	cpu.H = cpu.C
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_H_D() int {
+	// 0x62: Load the contents of register D into register H
+	cpu.H = cpu.D
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_H_E() int {
+	// 0x63: Load the contents of register E into register H
+	cpu.H = cpu.E
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_H_H() int {
+	// 0x64: Load the contents of register H into register H (does nothing)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_H_L() int {
+	// 0x65: Load the contents of register L into register H
+	cpu.H = cpu.L
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_H_HL() int {
+	// 0x66: Load the value at memory location HL into register H
+	cpu.H = cpu.memory.Read(cpu.HL())
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) LD_H_A() int {
+	// 0x67: Load the contents of register A into register H
+	cpu.H = cpu.A
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_L_B() int {
+	// 0x68: Load the contents of register B into register L
+	cpu.L = cpu.B
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_L_C() int {
+	// 0x69: Load the contents of register C into register L
+	cpu.L = cpu.C
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_L_D() int {
+	// 0x6A: Load the contents of register D into register L
+	cpu.L = cpu.D
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_L_E() int {
+	// 0x6B: Load the contents of register E into register L
+	cpu.L = cpu.E
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_L_H() int {
+	// 0x6C: Load the contents of register H into register L
+	cpu.L = cpu.H
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_L_L() int {
+	// 0x6D: Load the contents of register L into register L (does nothing)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_L_HL() int {
+	// 0x6E: Load the value at memory location HL into register L
+	cpu.L = cpu.memory.Read(cpu.HL())
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) LD_L_A() int {
+	// 0x6F: Load the contents of register A into register L
+	cpu.L = cpu.A
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_HL_B() int {
+	// 0x70: Store the value in register B at memory location HL
+	cpu.memory.Write(cpu.HL(), cpu.B)
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) LD_HL_C() int {
+	// 0x71: Store the value in register C at memory location HL
+	cpu.memory.Write(cpu.HL(), cpu.C)
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) LD_HL_D() int {
+	// 0x72: Store the value in register D at memory location HL
+	cpu.memory.Write(cpu.HL(), cpu.D)
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) LD_HL_E() int {
+	// 0x73: Store the value in register E at memory location HL
+	cpu.memory.Write(cpu.HL(), cpu.E)
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) LD_HL_H() int {
+	// 0x74: Store the value in register H at memory location HL
+	cpu.memory.Write(cpu.HL(), cpu.H)
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) LD_HL_L() int {
+	// 0x75: Store the value in register L at memory location HL
+	cpu.memory.Write(cpu.HL(), cpu.L)
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) HALT() int {
+	// 0x76: Halt the CPU until an interrupt is pending
+	if !cpu.IME && cpu.memory.PendingInterrupts() != 0 {
+		// HALT bug: the CPU doesn't halt, and fails to increment PC
+		// after fetching the next opcode
+		cpu.haltBug = true
+	} else {
+		cpu.halted = true
+	}
+
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_HL_A() int {
+	// 0x77: Store the value in register A at memory location HL
+	cpu.memory.Write(cpu.HL(), cpu.A)
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) LD_A_B() int {
+	// 0x78: Load the contents of register B into register A
+	cpu.A = cpu.B
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_A_C() int {
+	// 0x79: Load the contents of register C into register A
+	cpu.A = cpu.C
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_A_D() int {
+	// 0x7A: Load the contents of register D into register A
+	cpu.A = cpu.D
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_A_E() int {
+	// 0x7B: Load the contents of register E into register A
+	cpu.A = cpu.E
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_A_H() int {
+	// 0x7C: Load the contents of register H into register A
+	cpu.A = cpu.H
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_A_L() int {
+	// 0x7D: Load the contents of register L into register A
+	cpu.A = cpu.L
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) LD_A_HL() int {
+	// 0x7E: Load the value at memory location HL into register A
+	cpu.A = cpu.memory.Read(cpu.HL())
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) LD_A_A() int {
+	// 0x7F: Load the contents of register A into register A (does nothing)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) ADD_A_B() int {
+	// 0x80: A = A + B
+	cpu.add(cpu.B)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) ADD_A_C() int {
+	// 0x81: A = A + C
+	cpu.add(cpu.C)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) ADD_A_D() int {
+	// 0x82: A = A + D
+	cpu.add(cpu.D)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) ADD_A_E() int {
+	// 0x83: A = A + E
+	cpu.add(cpu.E)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) ADD_A_H() int {
+	// 0x84: A = A + H
+	cpu.add(cpu.H)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) ADD_A_L() int {
+	// 0x85: A = A + L
+	cpu.add(cpu.L)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) ADD_A_HL() int {
+	// 0x86: A = A + (HL)
+	cpu.add(cpu.memory.Read(cpu.HL()))
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) ADD_A_A() int {
+	// 0x87: A = A + A
+	cpu.add(cpu.A)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) ADC_A_B() int {
+	// 0x88: A = A + B + carry
+	cpu.adc(cpu.B)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) ADC_A_C() int {
+	// 0x89: A = A + C + carry
+	cpu.adc(cpu.C)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) ADC_A_D() int {
+	// 0x8A: A = A + D + carry
+	cpu.adc(cpu.D)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) ADC_A_E() int {
+	// 0x8B: A = A + E + carry
+	cpu.adc(cpu.E)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) ADC_A_H() int {
+	// 0x8C: A = A + H + carry
+	cpu.adc(cpu.H)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) ADC_A_L() int {
+	// 0x8D: A = A + L + carry
+	cpu.adc(cpu.L)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) ADC_A_HL() int {
+	// 0x8E: A = A + (HL) + carry
+	cpu.adc(cpu.memory.Read(cpu.HL()))
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) ADC_A_A() int {
+	// 0x8F: A = A + A + carry
+	cpu.adc(cpu.A)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) SUB_A_B() int {
+	// 0x90: A = A - B
+	cpu.sub(cpu.B)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) SUB_A_C() int {
+	// 0x91: A = A - C
+	cpu.sub(cpu.C)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) SUB_A_D() int {
+	// 0x92: A = A - D
+	cpu.sub(cpu.D)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) SUB_A_E() int {
+	// 0x93: A = A - E
+	cpu.sub(cpu.E)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) SUB_A_H() int {
+	// 0x94: A = A - H
+	cpu.sub(cpu.H)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) SUB_A_L() int {
+	// 0x95: A = A - L
+	cpu.sub(cpu.L)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) SUB_A_HL() int {
+	// 0x96: A = A - (HL)
+	cpu.sub(cpu.memory.Read(cpu.HL()))
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) SUB_A_A() int {
+	// 0x97: A = A - A
+	cpu.sub(cpu.A)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) SBC_A_B() int {
+	// 0x98: A = A - B - carry
+	cpu.sbc(cpu.B)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) SBC_A_C() int {
+	// 0x99: A = A - C - carry
+	cpu.sbc(cpu.C)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) SBC_A_D() int {
+	// 0x9A: A = A - D - carry
+	cpu.sbc(cpu.D)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) SBC_A_E() int {
+	// 0x9B: A = A - E - carry
+	cpu.sbc(cpu.E)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) SBC_A_H() int {
+	// 0x9C: A = A - H - carry
+	cpu.sbc(cpu.H)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) SBC_A_L() int {
+	// 0x9D: A = A - L - carry
+	cpu.sbc(cpu.L)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) SBC_A_HL() int {
+	// 0x9E: A = A - (HL) - carry
+	cpu.sbc(cpu.memory.Read(cpu.HL()))
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) SBC_A_A() int {
+	// 0x9F: A = A - A - carry
+	cpu.sbc(cpu.A)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) AND_A_B() int {
+	// 0xA0: A = A & B
+	cpu.and(cpu.B)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) AND_A_C() int {
+	// 0xA1: A = A & C
+	cpu.and(cpu.C)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) AND_A_D() int {
+	// 0xA2: A = A & D
+	cpu.and(cpu.D)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) AND_A_E() int {
+	// 0xA3: A = A & E
+	cpu.and(cpu.E)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) AND_A_H() int {
+	// 0xA4: A = A & H
+	cpu.and(cpu.H)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) AND_A_L() int {
+	// 0xA5: A = A & L
+	cpu.and(cpu.L)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) AND_A_HL() int {
+	// 0xA6: A = A & (HL)
+	cpu.and(cpu.memory.Read(cpu.HL()))
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) AND_A_A() int {
+	// 0xA7: A = A & A
+	cpu.and(cpu.A)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) XOR_A_B() int {
+	// 0xA8: A = A ^ B
+	cpu.xor(cpu.B)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) XOR_A_C() int {
+	// 0xA9: A = A ^ C
+	cpu.xor(cpu.C)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) XOR_A_D() int {
+	// 0xAA: A = A ^ D
+	cpu.xor(cpu.D)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) XOR_A_E() int {
+	// 0xAB: A = A ^ E
+	cpu.xor(cpu.E)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) XOR_A_H() int {
+	// 0xAC: A = A ^ H
+	cpu.xor(cpu.H)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) XOR_A_L() int {
+	// 0xAD: A = A ^ L
+	cpu.xor(cpu.L)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) XOR_A_HL() int {
+	// 0xAE: A = A ^ (HL)
+	cpu.xor(cpu.memory.Read(cpu.HL()))
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) XOR_A_A() int {
+	// 0xAF: A = A ^ A
+	cpu.xor(cpu.A)
+	cpu.PC++
+	return 1
+}
 
-	cpu.B = high
-	cpu.C = low
+func (cpu *CPU) OR_A_B() int {
+	// 0xB0: A = A | B
+	cpu.or(cpu.B)
 	cpu.PC++
+	return 1
 }
 
-func (cpu *CPU) JP_u16() {
-	// 0xC3: Set PC to next two bytes in program
+func (cpu *CPU) OR_A_C() int {
+	// 0xB1: A = A | C
+	cpu.or(cpu.C)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) OR_A_D() int {
+	// 0xB2: A = A | D
+	cpu.or(cpu.D)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) OR_A_E() int {
+	// 0xB3: A = A | E
+	cpu.or(cpu.E)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) OR_A_H() int {
+	// 0xB4: A = A | H
+	cpu.or(cpu.H)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) OR_A_L() int {
+	// 0xB5: A = A | L
+	cpu.or(cpu.L)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) OR_A_HL() int {
+	// 0xB6: A = A | (HL)
+	cpu.or(cpu.memory.Read(cpu.HL()))
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) OR_A_A() int {
+	// 0xB7: A = A | A
+	cpu.or(cpu.A)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) CP_A_B() int {
+	// 0xB8: Compare A and B, does not modify registers, only flags
+	cpu.cp(cpu.B)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) CP_A_C() int {
+	// 0xB9: Compare A and C, does not modify registers, only flags
+	cpu.cp(cpu.C)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) CP_A_D() int {
+	// 0xBA: Compare A and D, does not modify registers, only flags
+	cpu.cp(cpu.D)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) CP_A_E() int {
+	// 0xBB: Compare A and E, does not modify registers, only flags
+	cpu.cp(cpu.E)
 	cpu.PC++
-	low := cpu.memory.Read(cpu.PC)
+	return 1
+}
+
+func (cpu *CPU) CP_A_H() int {
+	// 0xBC: Compare A and H, does not modify registers, only flags
+	cpu.cp(cpu.H)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) CP_A_L() int {
+	// 0xBD: Compare A and L, does not modify registers, only flags
+	cpu.cp(cpu.L)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) CP_A_HL() int {
+	// 0xBE: Compare A and (HL), does not modify registers, only flags
+	cpu.cp(cpu.memory.Read(cpu.HL()))
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) CP_A_A() int {
+	// 0xBF: Compare A and A, does not modify registers, only flags
+	cpu.cp(cpu.A)
+	cpu.PC++
+	return 1
+}
+
+func (cpu *CPU) RET_NZ() int {
+	// 0xC0: If Z flag == 0, return from subroutine
+	return cpu.ret(!cpu.GetZeroFlag())
+}
+
+func (cpu *CPU) POP_BC() int {
+	// 0xC1: Pop two bytes from the stack into register BC
+	cpu.SetBC(cpu.popStack())
 	cpu.PC++
-	high := cpu.memory.Read(cpu.PC)
-	address := (uint16(high)<<8 | uint16(low))
-	cpu.PC = address
+	return 3
+}
+
+func (cpu *CPU) JP_NZ_u16() int {
+	// 0xC2: If Z flag == 0, jump to the address in the next two bytes
+	return cpu.jp(!cpu.GetZeroFlag())
+}
+
+func (cpu *CPU) JP_u16() int {
+	// 0xC3: Set PC to next two bytes in program
+	return cpu.jp(true)
+}
+
+func (cpu *CPU) CALL_NZ_u16() int {
+	// 0xC4: If Z flag == 0, call the address in the next two bytes
+	return cpu.call(!cpu.GetZeroFlag())
 }
 
-func (cpu *CPU) PUSH_BC() {
+func (cpu *CPU) PUSH_BC() int {
 	// 0xC5: Push BC to stack
 	cpu.PushStack(cpu.BC())
 	cpu.PC++
+	return 4
 }
 
-func (cpu *CPU) RET() {
+func (cpu *CPU) ADD_A_u8() int {
+	// 0xC6: A = A + u8
+	cpu.add(cpu.fetchU8())
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) RST_00H() int {
+	// 0xC7: Save PC to stack and jump to address 0x0000
+	cpu.PushStack(cpu.PC + 1)
+	cpu.PC = 0x0000
+	return 4
+}
+
+func (cpu *CPU) RET_Z() int {
+	// 0xC8: If Z flag == 1, return from subroutine
+	return cpu.ret(cpu.GetZeroFlag())
+}
+
+func (cpu *CPU) RET() int {
 	// 0xC9: Return from subroutine
-	low := cpu.memory.Read(cpu.SP)
-	cpu.SP++
-	high := cpu.memory.Read(cpu.SP)
-	cpu.SP++
+	cpu.PC = cpu.popStack()
+	return 4
+}
 
-	cpu.PC = uint16(high)<<8 | uint16(low)
+func (cpu *CPU) JP_Z_u16() int {
+	// 0xCA: If Z flag == 1, jump to the address in the next two bytes
+	return cpu.jp(cpu.GetZeroFlag())
 }
 
-func (cpu *CPU) ExecuteCBOpcode() {
+func (cpu *CPU) ExecuteCBOpcode() int {
 	// 0xCB: Prefixed opcodes
-	cpu.PC++
-	opcode := cpu.memory.Read(cpu.PC)
-	handler := opcodeCBTable[opcode]
+	opcode := cpu.memory.Read(cpu.PC + 1)
+	handler := cpu.opcodeCBTable[opcode]
 
-	if handler != nil {
-		// Execute the handler corresponding to the second byte
-		fmt.Printf("Executing CB-prefixed opcode: 0x%02X at PC 0x%04X\n", opcode, cpu.PC)
-		handler()
-	} else {
-		// Handle unrecognized opcode
-		fmt.Printf("Unhandled CB-prefixed opcode: 0x%02X at PC 0x%04X\n", opcode, cpu.PC)
-		fmt.Printf("\n\n\n")
+	if handler == nil {
+		// Leave PC on the prefix so the faulting instruction can be inspected
+		cpu.fault = &UnimplementedOpcodeError{Opcode: opcode, PC: cpu.PC, Prefixed: true}
+		return 0
 	}
 
 	cpu.PC++
+	cycles := handler()
+	cpu.PC++
+	return cycles
 }
 
-func (cpu *CPU) CALL_u16() {
+func (cpu *CPU) CALL_Z_u16() int {
+	// 0xCC: If Z flag == 1, call the address in the next two bytes
+	return cpu.call(cpu.GetZeroFlag())
+}
+
+func (cpu *CPU) CALL_u16() int {
 	// 0xCD: Push PC to stack, load next two bytes into PC
-	cpu.PC++
-	low := cpu.memory.Read(cpu.PC)
-	cpu.PC++
-	high := cpu.memory.Read(cpu.PC)
-	address := uint16(high)<<8 | uint16(low)
+	return cpu.call(true)
+}
 
+func (cpu *CPU) ADC_A_u8() int {
+	// 0xCE: A = A + u8 + carry
+	cpu.adc(cpu.fetchU8())
 	cpu.PC++
-	cpu.PushStack(cpu.PC)
+	return 2
+}
 
-	cpu.PC = address
+func (cpu *CPU) RST_08H() int {
+	// 0xCF: Save PC to stack and jump to address 0x0008
+	cpu.PushStack(cpu.PC + 1)
+	cpu.PC = 0x0008
+	return 4
 }
 
-func (cpu *CPU) POP_DE() {
-	// 0xD1: Pop two bytes from the stack into register DE
-	low := cpu.memory.Read(cpu.SP)
-	cpu.SP++
-	high := cpu.memory.Read(cpu.SP)
-	cpu.SP++
+func (cpu *CPU) RET_NC() int {
+	// 0xD0: If C flag == 0, return from subroutine
+	return cpu.ret(!cpu.GetCarryFlag())
+}
 
-	cpu.D = high
-	cpu.E = low
+func (cpu *CPU) POP_DE() int {
+	// 0xD1: Pop two bytes from the stack into register DE
+	cpu.SetDE(cpu.popStack())
 	cpu.PC++
+	return 3
+}
+
+func (cpu *CPU) JP_NC_u16() int {
+	// 0xD2: If C flag == 0, jump to the address in the next two bytes
+	return cpu.jp(!cpu.GetCarryFlag())
+}
+
+func (cpu *CPU) CALL_NC_u16() int {
+	// 0xD4: If C flag == 0, call the address in the next two bytes
+	return cpu.call(!cpu.GetCarryFlag())
 }
 
-func (cpu *CPU) PUSH_DE() {
+func (cpu *CPU) PUSH_DE() int {
 	// 0xD5: Push DE to stack
 	cpu.PushStack(cpu.DE())
 	cpu.PC++
+	return 4
 }
 
-func (cpu *CPU) LD_u8C_A() {
-	// 0xE0: Load value of register A into memory location of 0xFF00 + register C
-	address := 0xFF00 + uint16(cpu.C)
+func (cpu *CPU) SUB_A_u8() int {
+	// 0xD6: A = A - u8
+	cpu.sub(cpu.fetchU8())
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) RST_10H() int {
+	// 0xD7: Save PC to stack and jump to address 0x0010
+	cpu.PushStack(cpu.PC + 1)
+	cpu.PC = 0x0010
+	return 4
+}
+
+func (cpu *CPU) RET_C() int {
+	// 0xD8: If C flag == 1, return from subroutine
+	return cpu.ret(cpu.GetCarryFlag())
+}
+
+func (cpu *CPU) RETI() int {
+	// 0xD9: Return from subroutine and enable interrupts
+	cpu.PC = cpu.popStack()
+	cpu.IME = true
+	return 4
+}
+
+func (cpu *CPU) JP_C_u16() int {
+	// 0xDA: If C flag == 1, jump to the address in the next two bytes
+	return cpu.jp(cpu.GetCarryFlag())
+}
+
+func (cpu *CPU) CALL_C_u16() int {
+	// 0xDC: If C flag == 1, call the address in the next two bytes
+	return cpu.call(cpu.GetCarryFlag())
+}
+
+func (cpu *CPU) SBC_A_u8() int {
+	// 0xDE: A = A - u8 - carry
+	cpu.sbc(cpu.fetchU8())
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) RST_18H() int {
+	// 0xDF: Save PC to stack and jump to address 0x0018
+	cpu.PushStack(cpu.PC + 1)
+	cpu.PC = 0x0018
+	return 4
+}
+
+func (cpu *CPU) LD_u8C_A() int {
+	// 0xE0: Load value of register A into memory location of 0xFF00 + next byte
+	address := 0xFF00 + uint16(cpu.fetchU8())
 	cpu.memory.Write(address, cpu.A)
 	cpu.PC++
+	return 3
 }
 
-func (cpu *CPU) POP_HL() {
+func (cpu *CPU) POP_HL() int {
 	// 0xE1: Pop two bytes from the stack into register HL
-	low := cpu.memory.Read(cpu.SP)
-	cpu.SP++
-	high := cpu.memory.Read(cpu.SP)
-	cpu.SP++
+	cpu.SetHL(cpu.popStack())
+	cpu.PC++
+	return 3
+}
 
-	cpu.H = high
-	cpu.L = low
+func (cpu *CPU) LD_FFC_A() int {
+	// 0xE2: Load value of register A into memory location of 0xFF00 + register C
+	address := 0xFF00 + uint16(cpu.C)
+	cpu.memory.Write(address, cpu.A)
 	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) PUSH_HL() {
+func (cpu *CPU) PUSH_HL() int {
 	// 0xE5: Push HL to stack
 	cpu.PushStack(cpu.HL())
 	cpu.PC++
+	return 4
 }
 
-func (cpu *CPU) LD_u16_A() {
-	// 0xEA: Load register A to memory location of next two bytes
-	cpu.PC++
-	low := cpu.memory.Read(cpu.PC)
-	cpu.PC++
-	high := cpu.memory.Read(cpu.PC)
-	location := (uint16(high)<<8 | uint16(low))
-	cpu.memory.Write(location, cpu.A)
+func (cpu *CPU) AND_A_u8() int {
+	// 0xE6: A = A & u8
+	cpu.and(cpu.fetchU8())
 	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) RST_20H() int {
+	// 0xE7: Save PC to stack and jump to address 0x0020
+	cpu.PushStack(cpu.PC + 1)
+	cpu.PC = 0x0020
+	return 4
 }
 
-func (cpu *CPU) XOR_A_u8() {
-	// 0xEE: Perform XOR operation with value of the next byte on register A
+func (cpu *CPU) ADD_SP_r8() int {
+	// 0xE8: Add the signed next byte to SP
+	cpu.SP = cpu.addSPr8()
 	cpu.PC++
-	cpu.A ^= cpu.memory.Read(cpu.PC)
+	return 4
+}
 
-	cpu.SetZeroFlag(cpu.A == 0)
-	cpu.SetCarryFlag(false)
-	cpu.SetHalfCarryFlag(false)
-	cpu.SetSubtractFlag(false)
+func (cpu *CPU) JP_HL() int {
+	// 0xE9: Set PC to the value in HL
+	cpu.PC = cpu.HL()
+	return 1
+}
+
+func (cpu *CPU) LD_u16_A() int {
+	// 0xEA: Load register A to memory location of next two bytes
+	cpu.memory.Write(cpu.fetchU16(), cpu.A)
+	cpu.PC++
+	return 4
+}
 
+func (cpu *CPU) XOR_A_u8() int {
+	// 0xEE: A = A ^ u8
+	cpu.xor(cpu.fetchU8())
 	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) LD_A_u8C() {
-	// 0xF0: Load value at 0xFF00 + C into register A
-	address := 0xFF00 + uint16(cpu.C)
+func (cpu *CPU) RST_28H() int {
+	// 0xEF: Save PC to stack and jump to address 0x0028
+	cpu.PushStack(cpu.PC + 1)
+	cpu.PC = 0x0028
+	return 4
+}
+
+func (cpu *CPU) LD_A_u8C() int {
+	// 0xF0: Load value at 0xFF00 + next byte into register A
+	address := 0xFF00 + uint16(cpu.fetchU8())
 	cpu.A = cpu.memory.Read(address)
 	cpu.PC++
+	return 3
 }
 
-func (cpu *CPU) POP_AF() {
+func (cpu *CPU) POP_AF() int {
 	// 0xF1: Pop two bytes from the stack into register AF
-	low := cpu.memory.Read(cpu.SP)
-	cpu.SP++
-	high := cpu.memory.Read(cpu.SP)
-	cpu.SP++
+	cpu.SetAF(cpu.popStack() & 0xFFF0)
+	cpu.PC++
+	return 3
+}
 
-	cpu.A = high
-	cpu.F = low
+func (cpu *CPU) LD_A_FFC() int {
+	// 0xF2: Load value at 0xFF00 + register C into register A
+	address := 0xFF00 + uint16(cpu.C)
+	cpu.A = cpu.memory.Read(address)
 	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) DI() {
-	// 0xF3: Disable interrupts
+func (cpu *CPU) DI() int {
+	// 0xF3: Disable interrupts, cancelling a pending EI
 	cpu.IME = false
+	cpu.eiDelay = false
 	cpu.PC++
+	return 1
 }
 
-func (cpu *CPU) PUSH_AF() {
+func (cpu *CPU) PUSH_AF() int {
 	// 0xF5: Push AF to stack
 	cpu.PushStack(cpu.AF())
 	cpu.PC++
+	return 4
+}
+
+func (cpu *CPU) OR_A_u8() int {
+	// 0xF6: A = A | u8
+	cpu.or(cpu.fetchU8())
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) RST_30H() int {
+	// 0xF7: Save PC to stack and jump to address 0x0030
+	cpu.PushStack(cpu.PC + 1)
+	cpu.PC = 0x0030
+	return 4
+}
+
+func (cpu *CPU) LD_HL_SPr8() int {
+	// 0xF8: Load SP + the signed next byte into HL
+	cpu.SetHL(cpu.addSPr8())
+	cpu.PC++
+	return 3
 }
 
-func (cpu *CPU) LD_SP_HL() {
+func (cpu *CPU) LD_SP_HL() int {
 	// 0xF9: Set SP to the value in HL
 	cpu.SP = cpu.HL()
 	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) LD_A_u16() {
+func (cpu *CPU) LD_A_u16() int {
 	// 0xFA: Loads the value from memory address of next two bytes to register A
+	cpu.A = cpu.memory.Read(cpu.fetchU16())
 	cpu.PC++
-	low := cpu.memory.Read(cpu.PC)
+	return 4
+}
+
+func (cpu *CPU) EI() int {
+	// 0xFB: Enable interrupts after the next instruction
+	cpu.eiDelay = true
 	cpu.PC++
-	high := cpu.memory.Read(cpu.PC)
-	address := uint16(high)<<8 | uint16(low)
+	return 1
+}
 
-	cpu.A = cpu.memory.Read(address)
+func (cpu *CPU) CP_A_u8() int {
+	// 0xFE: Compare A and u8, does not modify registers, only flags
+	cpu.cp(cpu.fetchU8())
 	cpu.PC++
+	return 2
 }
 
-func (cpu *CPU) RST_38H() {
+func (cpu *CPU) RST_38H() int {
 	// 0xFF: Save PC to stack and jump to address 0x0038
-	cpu.PushStack(cpu.PC)
+	cpu.PushStack(cpu.PC + 1)
 	cpu.PC = 0x0038
+	return 4
+}
+
+// Helper functions //
+func boolToUint8(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (cpu *CPU) fetchU8() uint8 {
+	// Advance PC and read the operand byte it now points at
+	cpu.PC++
+	return cpu.memory.Read(cpu.PC)
+}
+
+func (cpu *CPU) fetchU16() uint16 {
+	// Read a little-endian 16-bit operand
+	low := cpu.fetchU8()
+	high := cpu.fetchU8()
+	return uint16(high)<<8 | uint16(low)
 }
 
-// CB-Prefixed Opcodes //
-func (cpu *CPU) RR_C() {
-	// 0x19: Rotate Right operation on register C
-	carry := cpu.C & 0x01
-	cpu.C = (cpu.C >> 1) | (boolToUint8(cpu.GetCarryFlag()) << 7)
+// The branch helpers return the machine cycles taken, which depend on
+// whether the condition held.
 
-	cpu.SetCarryFlag(carry == 1)
-	cpu.SetZeroFlag(cpu.C == 0)
+func (cpu *CPU) jr(condition bool) int {
+	offset := int8(cpu.fetchU8())
+	cpu.PC++
+
+	if condition {
+		cpu.PC += uint16(offset)
+		return 3
+	}
+	return 2
+}
+
+func (cpu *CPU) jp(condition bool) int {
+	address := cpu.fetchU16()
+	cpu.PC++
+
+	if condition {
+		cpu.PC = address
+		return 4
+	}
+	return 3
+}
+
+func (cpu *CPU) call(condition bool) int {
+	address := cpu.fetchU16()
+	cpu.PC++
+
+	if condition {
+		cpu.PushStack(cpu.PC)
+		cpu.PC = address
+		return 6
+	}
+	return 3
+}
+
+func (cpu *CPU) ret(condition bool) int {
+	// Conditional returns only; RET and RETI always take 4 cycles
+	if condition {
+		cpu.PC = cpu.popStack()
+		return 5
+	}
+	cpu.PC++
+	return 2
+}
+
+func (cpu *CPU) inc8(value uint8) uint8 {
+	result := value + 1
+
+	cpu.SetZeroFlag(result == 0)
 	cpu.SetSubtractFlag(false)
-	cpu.SetHalfCarryFlag(false)
+	cpu.SetHalfCarryFlag((value&0x0F)+1 > 0x0F)
+
+	return result
 }
 
-func (cpu *CPU) RR_D() {
-	// 0x1A: Rotate Right operation on register D
-	carry := cpu.D & 0x01
-	cpu.D = (cpu.D >> 1) | (boolToUint8(cpu.GetCarryFlag()) << 7)
+func (cpu *CPU) dec8(value uint8) uint8 {
+	result := value - 1
 
-	cpu.SetCarryFlag(carry == 1)
-	cpu.SetZeroFlag(cpu.D == 0)
+	cpu.SetZeroFlag(result == 0)
+	cpu.SetSubtractFlag(true)
+	cpu.SetHalfCarryFlag((value & 0x0F) == 0x00)
+
+	return result
+}
+
+func (cpu *CPU) add(value uint8) {
+	result := uint16(cpu.A) + uint16(value)
+
+	cpu.SetZeroFlag(uint8(result) == 0)
+	cpu.SetSubtractFlag(false)
+	cpu.SetHalfCarryFlag((cpu.A&0x0F)+(value&0x0F) > 0x0F)
+	cpu.SetCarryFlag(result > 0xFF)
+
+	cpu.A = uint8(result)
+}
+
+func (cpu *CPU) adc(value uint8) {
+	carry := boolToUint8(cpu.GetCarryFlag())
+	result := uint16(cpu.A) + uint16(value) + uint16(carry)
+
+	cpu.SetZeroFlag(uint8(result) == 0)
+	cpu.SetSubtractFlag(false)
+	cpu.SetHalfCarryFlag((cpu.A&0x0F)+(value&0x0F)+carry > 0x0F)
+	cpu.SetCarryFlag(result > 0xFF)
+
+	cpu.A = uint8(result)
+}
+
+func (cpu *CPU) sub(value uint8) {
+	cpu.cp(value)
+	cpu.A -= value
+}
+
+func (cpu *CPU) sbc(value uint8) {
+	carry := boolToUint8(cpu.GetCarryFlag())
+	result := int16(cpu.A) - int16(value) - int16(carry)
+
+	cpu.SetZeroFlag(uint8(result) == 0)
+	cpu.SetSubtractFlag(true)
+	cpu.SetHalfCarryFlag(int16(cpu.A&0x0F)-int16(value&0x0F)-int16(carry) < 0)
+	cpu.SetCarryFlag(result < 0)
+
+	cpu.A = uint8(result)
+}
+
+func (cpu *CPU) and(value uint8) {
+	cpu.A &= value
+
+	cpu.SetZeroFlag(cpu.A == 0)
+	cpu.SetSubtractFlag(false)
+	cpu.SetHalfCarryFlag(true)
+	cpu.SetCarryFlag(false)
+}
+
+func (cpu *CPU) xor(value uint8) {
+	cpu.A ^= value
+
+	cpu.SetZeroFlag(cpu.A == 0)
 	cpu.SetSubtractFlag(false)
 	cpu.SetHalfCarryFlag(false)
+	cpu.SetCarryFlag(false)
 }
 
-func (cpu *CPU) SRL_B() {
-	// 0x38: Shift bits in register B one bit to the right
-	carry := cpu.B & 0x01
-	cpu.B >>= 1
+func (cpu *CPU) or(value uint8) {
+	cpu.A |= value
 
-	cpu.SetCarryFlag(carry == 1)
-	cpu.SetZeroFlag(cpu.B == 0)
+	cpu.SetZeroFlag(cpu.A == 0)
 	cpu.SetSubtractFlag(false)
 	cpu.SetHalfCarryFlag(false)
+	cpu.SetCarryFlag(false)
 }
 
-// Helper functions //
-func boolToUint8(b bool) uint8 {
-	if b {
-		return 1
+func (cpu *CPU) cp(value uint8) {
+	// Compare A with value, only flags are modified
+	cpu.SetZeroFlag(cpu.A == value)
+	cpu.SetSubtractFlag(true)
+	cpu.SetHalfCarryFlag((cpu.A & 0x0F) < (value & 0x0F))
+	cpu.SetCarryFlag(cpu.A < value)
+}
+
+func (cpu *CPU) addHL(value uint16) {
+	result := uint32(cpu.HL()) + uint32(value)
+
+	cpu.SetSubtractFlag(false)
+	cpu.SetHalfCarryFlag((cpu.HL()&0x0FFF)+(value&0x0FFF) > 0x0FFF)
+	cpu.SetCarryFlag(result > 0xFFFF)
+
+	cpu.SetHL(uint16(result))
+}
+
+func (cpu *CPU) addSPr8() uint16 {
+	// SP + signed operand; H and C come from the unsigned low byte addition
+	offset := cpu.fetchU8()
+	result := cpu.SP + uint16(int8(offset))
+
+	cpu.SetZeroFlag(false)
+	cpu.SetSubtractFlag(false)
+	cpu.SetHalfCarryFlag((cpu.SP&0x0F)+uint16(offset&0x0F) > 0x0F)
+	cpu.SetCarryFlag((cpu.SP&0xFF)+uint16(offset) > 0xFF)
+
+	return result
+}
+
+func (cpu *CPU) rlc(value uint8) uint8 {
+	carry := value >> 7
+	result := (value << 1) | carry
+	cpu.setShiftFlags(result, carry)
+	return result
+}
+
+func (cpu *CPU) rrc(value uint8) uint8 {
+	carry := value & 0x01
+	result := (value >> 1) | (carry << 7)
+	cpu.setShiftFlags(result, carry)
+	return result
+}
+
+func (cpu *CPU) rl(value uint8) uint8 {
+	carry := value >> 7
+	result := (value << 1) | boolToUint8(cpu.GetCarryFlag())
+	cpu.setShiftFlags(result, carry)
+	return result
+}
+
+func (cpu *CPU) rr(value uint8) uint8 {
+	carry := value & 0x01
+	result := (value >> 1) | (boolToUint8(cpu.GetCarryFlag()) << 7)
+	cpu.setShiftFlags(result, carry)
+	return result
+}
+
+func (cpu *CPU) sla(value uint8) uint8 {
+	carry := value >> 7
+	result := value << 1
+	cpu.setShiftFlags(result, carry)
+	return result
+}
+
+func (cpu *CPU) sra(value uint8) uint8 {
+	carry := value & 0x01
+	result := (value >> 1) | (value & 0x80)
+	cpu.setShiftFlags(result, carry)
+	return result
+}
+
+func (cpu *CPU) swap(value uint8) uint8 {
+	result := (value << 4) | (value >> 4)
+	cpu.setShiftFlags(result, 0)
+	return result
+}
+
+func (cpu *CPU) srl(value uint8) uint8 {
+	carry := value & 0x01
+	result := value >> 1
+	cpu.setShiftFlags(result, carry)
+	return result
+}
+
+func (cpu *CPU) readR8(index uint8) uint8 {
+	// Operand encoding shared by the CB opcodes: B, C, D, E, H, L, (HL), A
+	switch index {
+	case 0:
+		return cpu.B
+	case 1:
+		return cpu.C
+	case 2:
+		return cpu.D
+	case 3:
+		return cpu.E
+	case 4:
+		return cpu.H
+	case 5:
+		return cpu.L
+	case 6:
+		return cpu.memory.Read(cpu.HL())
+	default:
+		return cpu.A
 	}
-	return 0
+}
+
+func (cpu *CPU) writeR8(index uint8, value uint8) {
+	switch index {
+	case 0:
+		cpu.B = value
+	case 1:
+		cpu.C = value
+	case 2:
+		cpu.D = value
+	case 3:
+		cpu.E = value
+	case 4:
+		cpu.H = value
+	case 5:
+		cpu.L = value
+	case 6:
+		cpu.memory.Write(cpu.HL(), value)
+	default:
+		cpu.A = value
+	}
+}
+
+func (cpu *CPU) setShiftFlags(result uint8, carry uint8) {
+	cpu.SetZeroFlag(result == 0)
+	cpu.SetSubtractFlag(false)
+	cpu.SetHalfCarryFlag(false)
+	cpu.SetCarryFlag(carry == 1)
 }
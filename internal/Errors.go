@@ -0,0 +1,33 @@
+package internal
+
+import "fmt"
+
+// UnimplementedOpcodeError is returned by Step when the CPU fetches an
+// opcode that has no handler in its dispatch table.
+type UnimplementedOpcodeError struct {
+	Opcode   uint8
+	PC       uint16
+	Prefixed bool // Opcode follows a 0xCB prefix
+}
+
+func (e *UnimplementedOpcodeError) Error() string {
+	if e.Prefixed {
+		return fmt.Sprintf("unimplemented opcode 0xCB 0x%02X at PC 0x%04X", e.Opcode, e.PC)
+	}
+	return fmt.Sprintf("unimplemented opcode 0x%02X at PC 0x%04X", e.Opcode, e.PC)
+}
+
+// IllegalOpcodeError is returned by Step when the CPU executes one of the
+// undefined opcodes. Real hardware locks up until it is powered off, so the
+// CPU keeps returning this error from then on.
+type IllegalOpcodeError struct {
+	Opcode uint8
+	PC     uint16
+}
+
+func (e *IllegalOpcodeError) Error() string {
+	return fmt.Sprintf("illegal opcode 0x%02X at PC 0x%04X, CPU locked up", e.Opcode, e.PC)
+}
+
+// illegalOpcodes are the unprefixed opcodes the SM83 does not define
+var illegalOpcodes = [...]uint8{0xD3, 0xDB, 0xDD, 0xE3, 0xE4, 0xEB, 0xEC, 0xED, 0xF4, 0xFC, 0xFD}
@@ -0,0 +1,55 @@
+package internal
+
+import "GoBoy/memory"
+
+// interruptPriority lists the interrupt sources in the order they are
+// serviced when several are pending at once
+var interruptPriority = [...]memory.Interrupt{
+	memory.InterruptVBlank,
+	memory.InterruptLCD,
+	memory.InterruptTimer,
+	memory.InterruptSerial,
+	memory.InterruptJoypad,
+}
+
+// handleInterrupts dispatches the highest priority pending interrupt and
+// returns the machine cycles spent doing so, or 0 if none was taken
+func (cpu *CPU) handleInterrupts() int {
+	pending := cpu.memory.PendingInterrupts()
+	if pending == 0 || cpu.stopped {
+		// Only joypad input brings the CPU out of STOP
+		return 0
+	}
+
+	// Any pending interrupt wakes a halted CPU, even with IME off
+	wakeCycles := 0
+	if cpu.halted {
+		cpu.halted = false
+		wakeCycles = 1
+	}
+
+	if !cpu.IME {
+		return 0
+	}
+
+	for _, interrupt := range interruptPriority {
+		if pending&(1<<interrupt) == 0 {
+			continue
+		}
+
+		if cpu.haltBug {
+			// The HALT was never left, so the interrupt returns to it
+			cpu.haltBug = false
+			cpu.PC--
+		}
+
+		cpu.IME = false
+		cpu.memory.ClearInterrupt(interrupt)
+		cpu.PushStack(cpu.PC)
+		cpu.PC = interrupt.Vector()
+
+		// Two wait states, two cycles to push PC and one to set PC
+		return 5 + wakeCycles
+	}
+	return 0
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"GoBoy/memory"
+	"testing"
+)
+
+// newTestCPU returns a CPU running a 32KB ROM-only cartridge with program
+// at the entry point and isr at the VBlank vector
+func newTestCPU(t *testing.T, program []byte, isr []byte) *CPU {
+	t.Helper()
+
+	rom := make([]byte, 0x8000)
+	copy(rom[0x0040:], isr)
+	copy(rom[0x0100:], program)
+
+	cart, err := memory.NewCartridge(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem, err := memory.NewMemory(cart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewCPU(mem)
+}
+
+func TestHaltBugWithEIAndPendingInterrupt(t *testing.T) {
+	program := []byte{
+		0x0E, 0x00, // 0x0100 LD C,0x00
+		0x3E, 0x01, // 0x0102 LD A,0x01
+		0xE0, 0xFF, // 0x0104 LDH (0xFF),A ; IE = VBlank
+		0xE0, 0x0F, // 0x0106 LDH (0x0F),A ; IF = VBlank
+		0xFB, // 0x0108 EI
+		0x76, // 0x0109 HALT
+		0x00, // 0x010A NOP
+	}
+	isr := []byte{
+		0x0C, // 0x0040 INC C
+		0xD9, // 0x0041 RETI
+	}
+	cpu := newTestCPU(t, program, isr)
+
+	// Up to and including HALT, which hits the bug as IME is still off
+	for range 6 {
+		if _, err := cpu.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The interrupt is dispatched and should return to the HALT
+	if _, err := cpu.Step(); err != nil {
+		t.Fatal(err)
+	}
+	if cpu.PC != 0x0040 {
+		t.Fatalf("PC = 0x%04X after dispatch, want 0x0040", cpu.PC)
+	}
+	if ret := uint16(cpu.memory.Read(cpu.SP+1))<<8 | uint16(cpu.memory.Read(cpu.SP)); ret != 0x0109 {
+		t.Errorf("pushed return address 0x%04X, want 0x0109", ret)
+	}
+
+	// INC C and RETI
+	for range 2 {
+		if _, err := cpu.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cpu.C != 1 {
+		t.Errorf("C = %d, the first ISR instruction should run once", cpu.C)
+	}
+	if cpu.PC != 0x0109 {
+		t.Errorf("PC = 0x%04X after RETI, want the HALT at 0x0109", cpu.PC)
+	}
+}
@@ -0,0 +1,85 @@
+package internal
+
+import "GoBoy/memory"
+
+// resetRegisters sets the registers to the values the boot ROM of the
+// emulated model leaves them at when it jumps to the cartridge at 0x0100.
+// Some of them depend on the cartridge header the boot ROM has just read.
+func (cpu *CPU) resetRegisters() {
+	model := cpu.memory.Model()
+	headerChecksum := cpu.memory.Read(0x014D)
+
+	cpu.SP = 0xFFFE
+	cpu.PC = 0x0100
+
+	switch model {
+	case memory.ModelDMG0:
+		cpu.A, cpu.F = 0x01, 0x00
+		cpu.B, cpu.C = 0xFF, 0x13
+		cpu.D, cpu.E = 0x00, 0xC1
+		cpu.H, cpu.L = 0x84, 0x03
+	case memory.ModelDMG, memory.ModelMGB:
+		cpu.A, cpu.F = 0x01, 0x80
+		if model == memory.ModelMGB {
+			cpu.A = 0xFF
+		}
+		// Left over from the header checksum calculation
+		if headerChecksum != 0 {
+			cpu.F |= 0x30
+		}
+		cpu.B, cpu.C = 0x00, 0x13
+		cpu.D, cpu.E = 0x00, 0xD8
+		cpu.H, cpu.L = 0x01, 0x4D
+	case memory.ModelSGB, memory.ModelSGB2:
+		cpu.A, cpu.F = 0x01, 0x00
+		if model == memory.ModelSGB2 {
+			cpu.A = 0xFF
+		}
+		cpu.B, cpu.C = 0x00, 0x14
+		cpu.D, cpu.E = 0x00, 0x00
+		cpu.H, cpu.L = 0xC0, 0x60
+	case memory.ModelCGB, memory.ModelAGB:
+		cpu.A, cpu.F = 0x11, 0x80
+		cpu.C = 0x00
+
+		if cpu.memory.Read(0x0143)&0x80 != 0 {
+			cpu.B = 0x00
+			cpu.D, cpu.E = 0xFF, 0x56
+			cpu.H, cpu.L = 0x00, 0x0D
+		} else {
+			// DMG compatibility mode, B holds the title checksum the boot
+			// ROM uses to pick a colour palette for Nintendo titles
+			cpu.B = 0x00
+			if cpu.nintendoLicensee() {
+				cpu.B = cpu.titleChecksum()
+			}
+			cpu.D, cpu.E = 0x00, 0x08
+			cpu.SetHL(0x007C)
+			if cpu.B == 0x43 || cpu.B == 0x58 {
+				cpu.SetHL(0x991A)
+			}
+		}
+
+		// The AGB boot ROM runs an extra INC B before handing over
+		if model == memory.ModelAGB {
+			cpu.F = 0x00
+			cpu.B = cpu.inc8(cpu.B)
+		}
+	}
+}
+
+func (cpu *CPU) nintendoLicensee() bool {
+	oldLicensee := cpu.memory.Read(0x014B)
+	if oldLicensee == 0x33 {
+		return cpu.memory.Read(0x0144) == '0' && cpu.memory.Read(0x0145) == '1'
+	}
+	return oldLicensee == 0x01
+}
+
+func (cpu *CPU) titleChecksum() uint8 {
+	sum := uint8(0)
+	for addr := uint16(0x0134); addr <= 0x0143; addr++ {
+		sum += cpu.memory.Read(addr)
+	}
+	return sum
+}
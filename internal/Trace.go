@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+)
+
+// Tracer is called with the CPU state right before each instruction
+// executes. Tracing is off unless one is installed with SetTracer.
+type Tracer interface {
+	Trace(cpu *CPU)
+}
+
+// SetTracer installs t as the instruction tracer, or disables tracing when
+// t is nil
+func (cpu *CPU) SetTracer(t Tracer) {
+	cpu.tracer = t
+}
+
+// DoctorTracer writes one line per instruction in the format used by
+// gameboy-doctor, so traces can be diffed against reference logs:
+//
+//	A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0100 PCMEM:00,C3,13,02
+type DoctorTracer struct {
+	w   io.Writer
+	err error
+}
+
+// NewDoctorTracer returns a tracer writing to w. Wrap w in a bufio.Writer
+// when it is a file, tracing emits a line for every instruction.
+func NewDoctorTracer(w io.Writer) *DoctorTracer {
+	return &DoctorTracer{w: w}
+}
+
+func (t *DoctorTracer) Trace(cpu *CPU) {
+	if t.err != nil {
+		return
+	}
+
+	pc := cpu.PC
+	_, t.err = fmt.Fprintf(t.w,
+		"A:%02X F:%02X B:%02X C:%02X D:%02X E:%02X H:%02X L:%02X SP:%04X PC:%04X PCMEM:%02X,%02X,%02X,%02X\n",
+		cpu.A, cpu.F, cpu.B, cpu.C, cpu.D, cpu.E, cpu.H, cpu.L, cpu.SP, pc,
+		cpu.memory.Read(pc), cpu.memory.Read(pc+1), cpu.memory.Read(pc+2), cpu.memory.Read(pc+3))
+}
+
+// Err returns the first error encountered writing the trace, after which
+// the tracer stops writing
+func (t *DoctorTracer) Err() error {
+	return t.err
+}
@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// romExtensions are the file names recognised as ROM images inside a
+// .zip archive
+var romExtensions = []string{".gb", ".gbc", ".cgb", ".sgb"}
+
+// maxROMSize is the largest Game Boy ROM, 8MB. Anything read or unpacked
+// is limited to it, so a small archive can't expand to fill memory.
+const maxROMSize = 8 * 1024 * 1024
+
+var errROMTooLarge = fmt.Errorf("ROM is larger than %d bytes", maxROMSize)
+
+// readROM reads r to the end, failing once it passes maxROMSize
+func readROM(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxROMSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxROMSize {
+		return nil, errROMTooLarge
+	}
+	return data, nil
+}
+
+// unpackROM returns the ROM image inside a .zip or .gzip archive, or data
+// itself when it isn't one. Archives are recognised by their signature,
+// not their name.
+func unpackROM(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return unzipROM(data)
+	case bytes.HasPrefix(data, []byte{0x1F, 0x8B}):
+		return gunzipROM(data)
+	}
+	return data, nil
+}
+
+// unzipROM extracts the single ROM image in a .zip archive
+func unzipROM(data []byte) ([]byte, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip archive: %w", err)
+	}
+
+	var rom *zip.File
+	for _, file := range archive.File {
+		if !isROMName(file.Name) {
+			continue
+		}
+		if rom != nil {
+			return nil, fmt.Errorf("zip archive has more than one ROM: %s and %s", rom.Name, file.Name)
+		}
+		rom = file
+	}
+	if rom == nil {
+		return nil, fmt.Errorf("zip archive has no ROM, expected a %s file", strings.Join(romExtensions, ", "))
+	}
+
+	if rom.UncompressedSize64 > maxROMSize {
+		return nil, fmt.Errorf("failed to extract %s: %w", rom.Name, errROMTooLarge)
+	}
+
+	r, err := rom.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract %s: %w", rom.Name, err)
+	}
+	defer r.Close()
+
+	// The size in the archive isn't trusted
+	unpacked, err := readROM(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract %s: %w", rom.Name, err)
+	}
+	return unpacked, nil
+}
+
+// gunzipROM decompresses a gzipped ROM image
+func gunzipROM(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip archive: %w", err)
+	}
+	defer r.Close()
+
+	unpacked, err := readROM(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress ROM: %w", err)
+	}
+	return unpacked, nil
+}
+
+func isROMName(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, romExt := range romExtensions {
+		if ext == romExt {
+			return true
+		}
+	}
+	return false
+}
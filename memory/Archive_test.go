@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"testing"
+)
+
+func gzipData(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipData(t *testing.T, name string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestUnpackROM(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	rom[0x0100] = 0x42
+
+	for name, data := range map[string][]byte{
+		"gzip": gzipData(t, rom),
+		"zip":  zipData(t, "game.gb", rom),
+	} {
+		unpacked, err := unpackROM(data)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !bytes.Equal(unpacked, rom) {
+			t.Errorf("%s: unpacked %d bytes that don't match the ROM", name, len(unpacked))
+		}
+	}
+}
+
+func TestUnpackROMTooLarge(t *testing.T) {
+	huge := make([]byte, maxROMSize+1)
+
+	for name, data := range map[string][]byte{
+		"gzip": gzipData(t, huge),
+		"zip":  zipData(t, "game.gb", huge),
+	} {
+		if _, err := unpackROM(data); !errors.Is(err, errROMTooLarge) {
+			t.Errorf("%s: error is %v, want errROMTooLarge", name, err)
+		}
+	}
+}
+
+func TestReadCartridgeTooLarge(t *testing.T) {
+	r := io.LimitReader(zeroReader{}, maxROMSize+1)
+	if _, err := ReadCartridge(r); !errors.Is(err, errROMTooLarge) {
+		t.Errorf("error is %v, want errROMTooLarge", err)
+	}
+}
+
+// zeroReader reads zeros forever
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
@@ -0,0 +1,68 @@
+package memory
+
+import "fmt"
+
+const (
+	addrBOOT = 0xFF50
+
+	dmgBootROMSize = 0x100
+	cgbBootROMSize = 0x900
+)
+
+// LoadBootROM maps a boot ROM image over the start of the cartridge ROM, so
+// execution can begin at 0x0000 with the real startup sequence. A DMG, MGB
+// or SGB boot ROM covers 0x0000-0x00FF; a CGB or AGB one also covers
+// 0x0200-0x08FF, leaving the cartridge header visible in between. The image
+// has to be the size of the memory's model's. The overlay stays until the
+// boot ROM writes to 0xFF50.
+func (mem *Memory) LoadBootROM(data []byte) error {
+	size := dmgBootROMSize
+	if mem.model.IsCGB() {
+		size = cgbBootROMSize
+	}
+	if len(data) != size {
+		return fmt.Errorf("invalid boot ROM for the %s, expected %d bytes but got %d", mem.model, size, len(data))
+	}
+
+	mem.bootROM = data
+	mem.bootROMMapped = true
+	mem.resetIOPowerOn()
+	return nil
+}
+
+// BootROMMapped reports whether the boot ROM is still overlaid on the
+// cartridge, in which case the CPU has to start from power on at 0x0000
+func (mem *Memory) BootROMMapped() bool {
+	return mem.bootROMMapped
+}
+
+// writeBOOT handles a write to 0xFF50. Any non-zero value unmaps the boot
+// ROM until the next power cycle.
+func (mem *Memory) writeBOOT(addr uint16, value byte) {
+	if value != 0 {
+		mem.bootROMMapped = false
+	}
+}
+
+func (mem *Memory) inBootROM(addr uint16) bool {
+	if addr < dmgBootROMSize {
+		return true
+	}
+	return addr >= 0x200 && int(addr) < len(mem.bootROM)
+}
+
+// resetIOPowerOn replaces the post-boot register values with the power on
+// state, for the boot ROM to initialise itself
+func (mem *Memory) resetIOPowerOn() {
+	mem.io[0x04] = 0x00 // DIV
+	mem.io[0x0F] = 0x00 // IF
+	for addr := 0x10; addr <= 0x26; addr++ {
+		mem.io[addr] = 0x00 // Sound registers, APU off
+	}
+	mem.io[0x40] = 0x00 // LCDC
+	mem.io[0x41] = 0x80 // STAT
+	mem.io[0x44] = 0x00 // LY
+	mem.io[0x47] = 0x00 // BGP
+	mem.io[0x48] = 0x00 // OBP0
+	mem.io[0x49] = 0x00 // OBP1
+}
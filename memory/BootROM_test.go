@@ -0,0 +1,32 @@
+package memory
+
+import "testing"
+
+func TestLoadBootROMSizeForModel(t *testing.T) {
+	tests := []struct {
+		model Model
+		size  int
+		ok    bool
+	}{
+		{ModelDMG, dmgBootROMSize, true},
+		{ModelDMG, cgbBootROMSize, false},
+		{ModelMGB, dmgBootROMSize, true},
+		{ModelSGB, dmgBootROMSize, true},
+		{ModelSGB2, cgbBootROMSize, false},
+		{ModelCGB, cgbBootROMSize, true},
+		{ModelCGB, dmgBootROMSize, false},
+		{ModelAGB, cgbBootROMSize, true},
+		{ModelAGB, dmgBootROMSize, false},
+	}
+
+	for _, test := range tests {
+		mem := newTestMemory(t, test.model, 0x80)
+		err := mem.LoadBootROM(make([]byte, test.size))
+		if (err == nil) != test.ok {
+			t.Errorf("%s with a %d byte boot ROM: error is %v, want ok = %v", test.model, test.size, err, test.ok)
+		}
+		if mem.BootROMMapped() != test.ok {
+			t.Errorf("%s with a %d byte boot ROM: BootROMMapped() = %v", test.model, test.size, mem.BootROMMapped())
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package memory
+
+// CGB banking and VRAM DMA registers
+const (
+	addrVBK   = 0xFF4F
+	addrHDMA1 = 0xFF51
+	addrHDMA2 = 0xFF52
+	addrHDMA3 = 0xFF53
+	addrHDMA4 = 0xFF54
+	addrHDMA5 = 0xFF55
+	addrSVBK  = 0xFF70
+
+	vramBankSize = 0x2000
+	wramBankSize = 0x1000
+
+	// hdmaBlockSize is the bytes copied per HBlank, and the unit HDMA5
+	// counts lengths in
+	hdmaBlockSize = 0x10
+	// hdmaBlockCycles is the M-cycles the CPU is stalled per block at
+	// normal speed. The copy runs at a fixed rate, so it takes twice as
+	// many M-cycles in double speed mode.
+	hdmaBlockCycles = 8
+)
+
+// vramDMA is the CGB's DMA from ROM or RAM into VRAM, either all at once
+// or one block per HBlank
+type vramDMA struct {
+	source      uint16
+	dest        uint16 // Offset into VRAM
+	blocks      int    // Blocks left to copy
+	hblank      bool   // An HBlank transfer is running
+	stallCycles int    // M-cycles the CPU owes for copies, see TakeStall
+}
+
+// vramBank returns the VRAM bank selected by VBK, always 0 outside CGB mode
+func (mem *Memory) vramBank() int {
+	if !mem.cgb {
+		return 0
+	}
+	return int(mem.readIO(addrVBK) & 0x01)
+}
+
+// wramBank returns the WRAM bank mapped at 0xD000-0xDFFF. SVBK selects
+// banks 1-7, with 0 also meaning 1. Outside CGB mode it is always 1.
+func (mem *Memory) wramBank() int {
+	if !mem.cgb {
+		return 1
+	}
+	return max(1, int(mem.readIO(addrSVBK)&0x07))
+}
+
+// wramOffset returns the index into wram for an address in 0xC000-0xDFFF
+func (mem *Memory) wramOffset(addr uint16) int {
+	offset := int(addr & 0x1FFF)
+	if offset < wramBankSize {
+		return offset
+	}
+	return mem.wramBank()*wramBankSize + offset - wramBankSize
+}
+
+// vramOffset returns the index into vram for an address in 0x8000-0x9FFF
+func (mem *Memory) vramOffset(addr uint16) int {
+	return mem.vramBank()*vramBankSize + int(addr&0x1FFF)
+}
+
+// readHDMA reads the VRAM DMA registers, which only exist in CGB mode
+func (mem *Memory) readHDMA(addr uint16) byte {
+	switch addr {
+	case addrHDMA5:
+		// Blocks left minus one, bit 7 clear while an HBlank transfer runs
+		if mem.hdma.blocks == 0 {
+			return 0xFF
+		}
+		remaining := byte(mem.hdma.blocks-1) & 0x7F
+		if !mem.hdma.hblank {
+			// Cancelled
+			remaining |= 0x80
+		}
+		return remaining
+	}
+	// HDMA1-4 are write only
+	return 0xFF
+}
+
+func (mem *Memory) writeHDMA(addr uint16, value byte) {
+	switch addr {
+	case addrHDMA1:
+		mem.hdma.source = mem.hdma.source&0x00FF | uint16(value)<<8
+	case addrHDMA2:
+		// The low 4 bits are ignored, transfers are block aligned
+		mem.hdma.source = mem.hdma.source&0xFF00 | uint16(value&0xF0)
+	case addrHDMA3:
+		mem.hdma.dest = mem.hdma.dest&0x00FF | uint16(value&0x1F)<<8
+	case addrHDMA4:
+		mem.hdma.dest = mem.hdma.dest&0xFF00 | uint16(value&0xF0)
+	case addrHDMA5:
+		mem.startHDMA(value)
+	}
+}
+
+// startHDMA starts, or cancels, a transfer on a write to HDMA5. Bits 0-6
+// are the length in blocks minus one, and bit 7 selects an HBlank transfer
+// rather than a general purpose one.
+func (mem *Memory) startHDMA(value byte) {
+	if mem.hdma.hblank && value&0x80 == 0 {
+		// Writing bit 7 clear stops a running HBlank transfer
+		mem.hdma.hblank = false
+		return
+	}
+
+	mem.hdma.blocks = int(value&0x7F) + 1
+	if value&0x80 == 0 {
+		// General purpose, the CPU is stalled until it is all copied
+		for mem.hdma.blocks > 0 {
+			mem.copyHDMABlock()
+		}
+		return
+	}
+
+	mem.hdma.hblank = true
+	if mem.readIO(addrSTAT)&0x03 == 0 {
+		// Already in HBlank, or the LCD is off, so a block goes right away
+		mem.copyHDMABlock()
+	}
+}
+
+// HBlank is called by the PPU at the start of each HBlank, when an HBlank
+// VRAM DMA copies its next block
+func (mem *Memory) HBlank() {
+	if mem.hdma.hblank {
+		mem.copyHDMABlock()
+	}
+}
+
+// copyHDMABlock copies one 16 byte block and charges the CPU for it
+func (mem *Memory) copyHDMABlock() {
+	for i := 0; i < hdmaBlockSize; i++ {
+		value := byte(0xFF)
+		if mem.hdma.source < 0x8000 || mem.hdma.source >= 0xA000 {
+			// VRAM can't be a source, it is the bus being written
+			value = mem.read(mem.hdma.source)
+		}
+		mem.vram[mem.vramBank()*vramBankSize+int(mem.hdma.dest)] = value
+		mem.hdma.source++
+		mem.hdma.dest++
+	}
+
+	cycles := hdmaBlockCycles
+	if mem.DoubleSpeed() {
+		cycles *= 2
+	}
+	mem.hdma.stallCycles += cycles
+
+	mem.hdma.blocks--
+	if mem.hdma.dest >= vramBankSize {
+		// The destination can't wrap out of VRAM, the transfer ends
+		mem.hdma.dest &= vramBankSize - 1
+		mem.hdma.blocks = 0
+	}
+	if mem.hdma.blocks == 0 {
+		mem.hdma.hblank = false
+	}
+}
+
+// TakeStall returns the M-cycles the CPU has been stalled for by VRAM DMA
+// since the last call. The CPU adds them to the instruction that was
+// running, so the rest of the hardware is stepped through them.
+func (mem *Memory) TakeStall() int {
+	cycles := mem.hdma.stallCycles
+	mem.hdma.stallCycles = 0
+	return cycles
+}
@@ -1,63 +1,201 @@
 package memory
 
 import (
+	"GoBoy/romdb"
+	"crypto/sha1"
 	"encoding/hex"
 	"fmt"
+	"hash/crc32"
+	"io"
 	"os"
 )
 
 type Cartridge struct {
-	rom     []byte
-	title   string
-	mbcType byte
-	romSize int
-	ramSize int
+	rom      []byte
+	header   Header
+	warnings []string
+	patch    string // Path of the patch applied to the ROM, if any
+
+	entry      romdb.Entry // Database entry, when identified
+	identified bool
+}
+
+// Header returns the cartridge header as the ROM declares it
+func (cart *Cartridge) Header() Header {
+	return cart.header
+}
+
+// Warnings returns the problems found in the header. None of them stop
+// the cartridge from loading, though a bad header checksum would stop a
+// real Game Boy from starting it.
+func (cart *Cartridge) Warnings() []string {
+	return cart.warnings
+}
+
+// CRC32 returns the CRC-32 of the ROM
+func (cart *Cartridge) CRC32() uint32 {
+	return crc32.ChecksumIEEE(cart.rom)
+}
+
+// SHA1 returns the SHA-1 of the ROM
+func (cart *Cartridge) SHA1() [sha1.Size]byte {
+	return sha1.Sum(cart.rom)
+}
+
+// Identify looks the ROM up in db. When it is found, the header is
+// corrected with the entry's overrides and the entry is returned.
+// Cartridges are identified against the built-in database when they are
+// loaded, this checks another one, such as one with a user's DAT loaded.
+func (cart *Cartridge) Identify(db *romdb.Database) (romdb.Entry, bool) {
+	entry, ok := db.Lookup(cart.rom)
+	if !ok {
+		return romdb.Entry{}, false
+	}
+
+	cart.entry = entry
+	cart.identified = true
+	cart.header = parseHeader(cart.rom)
+	if override := entry.Overrides.CartridgeType; override != nil {
+		cart.header.CartridgeType = *override
+	}
+	if override := entry.Overrides.ROMSize; override != nil {
+		cart.header.ROMSize = getROMSize(*override)
+	}
+	if override := entry.Overrides.RAMSize; override != nil {
+		cart.header.RAMSize = getRAMSize(*override)
+	}
+	cart.checkHeader()
+	return entry, true
+}
+
+// Identity returns the database entry for the ROM, if it was identified
+func (cart *Cartridge) Identity() (romdb.Entry, bool) {
+	return cart.entry, cart.identified
 }
 
 func (cart *Cartridge) Debug() {
-	fmt.Println("Game Title:", cart.title)
-	fmt.Println("MBC Type:", hex.EncodeToString([]byte{cart.mbcType}))
-	fmt.Println("ROM Size:", cart.romSize, "bytes")
-	fmt.Println("RAM Size:", cart.ramSize, "bytes")
+	header := cart.header
+	if cart.identified {
+		fmt.Println("Database Name:", cart.entry.Name)
+		if cart.entry.Region != "" {
+			fmt.Println("Region:", cart.entry.Region)
+		}
+	} else {
+		fmt.Println("Database Name: unknown ROM")
+	}
+	fmt.Printf("CRC32: %08X\n", cart.CRC32())
+	fmt.Println("Game Title:", header.Title)
+	if header.Manufacturer != "" {
+		fmt.Println("Manufacturer:", header.Manufacturer)
+	}
+	fmt.Println("Licensee:", header.Licensee, header.LicenseeName)
+	fmt.Println("CGB Support:", header.CGB)
+	fmt.Println("SGB Support:", header.SGB)
+	fmt.Println("Destination:", header.Destination)
+	fmt.Println("Mask ROM Version:", header.MaskROMVersion)
+	fmt.Println("MBC Type:", hex.EncodeToString([]byte{header.CartridgeType}))
+	fmt.Println("ROM Size:", header.ROMSize, "bytes")
+	fmt.Println("RAM Size:", header.RAMSize, "bytes")
+	if cart.patch != "" {
+		fmt.Println("Patch:", cart.patch)
+	}
+	for _, warning := range cart.warnings {
+		fmt.Println("Warning:", warning)
+	}
+}
+
+// LoadCartridge loads a ROM image from a file. The path is used as given,
+// relative to the working directory. A .zip or .gz archive holding a single
+// ROM is unpacked. An IPS, UPS or BPS patch with the same name as the ROM,
+// such as game.bps next to game.gb, is applied.
+func LoadCartridge(path string) (*Cartridge, error) {
+	return LoadPatchedCartridge(path, findPatch(path))
 }
 
-func LoadCartridge(fileName string) (*Cartridge, error) {
-	data, err := os.ReadFile("../roms/" + fileName)
+// LoadPatchedCartridge loads a ROM image from a file like LoadCartridge,
+// applying the given patch instead of looking for one. An empty patchPath
+// loads the ROM unpatched.
+func LoadPatchedCartridge(path string, patchPath string) (*Cartridge, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load ROM: %w", err)
 	}
 
-	if len(data) < 0x150 {
-		return nil, fmt.Errorf("invalid ROM file, too small")
+	data, err = unpackROM(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ROM: %w", err)
+	}
+
+	if patchPath != "" {
+		data, err = applyPatchFile(data, patchPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cart, err := newCartridge(data)
+	if err != nil {
+		return nil, err
 	}
+	cart.patch = patchPath
+	return cart, nil
+}
 
-	return &Cartridge{
-		rom:     data,
-		title:   parseTitle(data),
-		mbcType: data[0x147],
-		romSize: getROMSize(data[0x148]),
-		ramSize: getRAMSize(data[0x149]),
-	}, nil
+// ReadCartridge loads a ROM image, or an archive holding one, from r. At
+// most 8MB is read, the size of the largest ROM.
+func ReadCartridge(r io.Reader) (*Cartridge, error) {
+	data, err := readROM(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ROM: %w", err)
+	}
+	return NewCartridge(data)
 }
 
-func parseTitle(data []byte) string {
-	titleBytes := data[0x134:0x144]
-	title := string(titleBytes)
-	return title
+// NewCartridge creates a cartridge from a ROM image, or an archive holding
+// one, already in memory
+func NewCartridge(data []byte) (*Cartridge, error) {
+	data, err := unpackROM(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ROM: %w", err)
+	}
+	return newCartridge(data)
 }
 
-func getROMSize(code byte) int {
-	romSizes := []int{32 * 1024, 64 * 1024, 128 * 1024, 256 * 1024, 512 * 1024, 1024 * 1024, 2048 * 1024, 4096 * 1024, 8192 * 1024}
-	if int(code) < len(romSizes) {
-		return romSizes[code]
+// newCartridge creates a cartridge from an unpacked ROM image
+func newCartridge(data []byte) (*Cartridge, error) {
+	if len(data) < headerEnd {
+		return nil, fmt.Errorf("invalid ROM file, too small")
+	}
+
+	cart := &Cartridge{
+		rom:    data,
+		header: parseHeader(data),
 	}
-	return 0
+	cart.checkHeader()
+	cart.Identify(romdb.Embedded())
+	return cart, nil
 }
 
-func getRAMSize(code byte) int {
-	ramSizes := []int{0, 2048, 8192, 32768, 131072}
-	if int(code) < len(ramSizes) {
-		return ramSizes[code]
+// checkHeader records a warning for each header field that doesn't match
+// the ROM
+func (cart *Cartridge) checkHeader() {
+	header := cart.header
+	cart.warnings = nil
+
+	if cart.identified && cart.entry.BadDump {
+		cart.warnings = append(cart.warnings, "ROM is a known bad dump")
+	}
+	if cart.identified && cart.entry.Overrides != (romdb.Overrides{}) {
+		cart.warnings = append(cart.warnings, "header is wrong, corrected from the ROM database")
+	}
+
+	if checksum := computeHeaderChecksum(cart.rom); checksum != header.HeaderChecksum {
+		cart.warnings = append(cart.warnings, fmt.Sprintf("header checksum is 0x%02X but should be 0x%02X", header.HeaderChecksum, checksum))
+	}
+	if checksum := computeGlobalChecksum(cart.rom); checksum != header.GlobalChecksum {
+		cart.warnings = append(cart.warnings, fmt.Sprintf("global checksum is 0x%04X but should be 0x%04X", header.GlobalChecksum, checksum))
+	}
+	if header.ROMSize != len(cart.rom) {
+		cart.warnings = append(cart.warnings, fmt.Sprintf("header declares %d bytes of ROM but the file has %d", header.ROMSize, len(cart.rom)))
 	}
-	return 0
 }
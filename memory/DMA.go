@@ -0,0 +1,78 @@
+package memory
+
+const (
+	addrDMA = 0xFF46
+
+	// oamDMALength is the bytes an OAM DMA copies, one per M-cycle
+	oamDMALength = 160
+	// oamDMAStartup is the M-cycles between the write to 0xFF46 and the
+	// first byte, spent setting up the transfer
+	oamDMAStartup = 1
+)
+
+// oamDMA copies 160 bytes from source to OAM, one per M-cycle. While it
+// copies it owns the bus, leaving the CPU only HRAM and the I/O registers.
+type oamDMA struct {
+	active   bool
+	blocking bool // Bytes are being copied, so the CPU is off the bus
+	started  bool // Started by the instruction Tick is about to be called for
+	source   uint16
+	index    int  // Next byte to copy
+	startup  int  // M-cycles left before the first byte
+	value    byte // Last byte copied, what the CPU sees on the bus
+}
+
+// startOAMDMA begins a transfer from page value, restarting any transfer
+// already running
+func (mem *Memory) startOAMDMA(value byte) {
+	source := uint16(value) << 8
+	if source >= 0xE000 {
+		// Above WRAM the DMA reads the echo of it
+		source -= 0x2000
+	}
+
+	mem.dma.active = true
+	mem.dma.started = true
+	mem.dma.source = source
+	mem.dma.index = 0
+	mem.dma.startup = oamDMAStartup
+}
+
+// tickOAMDMA advances a running transfer by the given M-cycles
+func (mem *Memory) tickOAMDMA(cycles int) {
+	if mem.dma.started {
+		// These are the cycles of the instruction that wrote 0xFF46. Stores
+		// write on their last M-cycle, so the transfer starts counting
+		// from the next one.
+		mem.dma.started = false
+		return
+	}
+
+	for ; cycles > 0 && mem.dma.active; cycles-- {
+		if mem.dma.startup > 0 {
+			mem.dma.startup--
+			continue
+		}
+
+		mem.dma.blocking = true
+		mem.dma.value = mem.read(mem.dma.source + uint16(mem.dma.index))
+		mem.oam[mem.dma.index] = mem.dma.value
+		mem.dma.index++
+		if mem.dma.index == oamDMALength {
+			mem.dma.active = false
+			mem.dma.blocking = false
+		}
+	}
+}
+
+// OAMDMAActive reports whether an OAM DMA transfer is running
+func (mem *Memory) OAMDMAActive() bool {
+	return mem.dma.active
+}
+
+// dmaBlocks reports whether a running OAM DMA keeps the CPU from addr.
+// Only HRAM, the I/O registers and IE are off the DMA's bus. The bus is
+// free while a transfer sets up, unless it restarted one that was copying.
+func (mem *Memory) dmaBlocks(addr uint16) bool {
+	return mem.dma.blocking && addr < 0xFF00
+}
@@ -0,0 +1,53 @@
+package memory
+
+import "testing"
+
+func TestOAMDMATiming(t *testing.T) {
+	mem := newTestMemory(t, ModelDMG, 0x00)
+	for i := range oamDMALength {
+		mem.Write(0xC000+uint16(i), byte(i+1))
+	}
+
+	// LDH (0x46),A writes on its third and last M-cycle, and the CPU
+	// steps the rest of the hardware by all three afterwards
+	mem.Write(addrDMA, 0xC0)
+	mem.Tick(3)
+	if mem.oam[0] != 0 || mem.dmaBlocks(0xC000) {
+		t.Fatal("transfer started during the instruction that wrote 0xFF46")
+	}
+
+	// One M-cycle to set up, with the bus still free
+	mem.Tick(1)
+	if mem.oam[0] != 0 {
+		t.Fatal("first byte copied during the setup M-cycle")
+	}
+	if got := mem.Read(0xC010); got != 0x11 {
+		t.Fatalf("CPU read 0x%02X during setup, want WRAM's 0x11", got)
+	}
+
+	// Then one byte per M-cycle, with the CPU seeing the byte being copied
+	for i := range oamDMALength {
+		mem.Tick(1)
+		if mem.oam[i] != byte(i+1) {
+			t.Fatalf("OAM byte %d not copied on M-cycle %d after the write", i, i+2)
+		}
+		if i+1 < oamDMALength && mem.oam[i+1] != 0 {
+			t.Fatalf("OAM byte %d copied early", i+1)
+		}
+		if i+1 < oamDMALength {
+			if got := mem.Read(0xC010); got != byte(i+1) {
+				t.Fatalf("CPU read 0x%02X on M-cycle %d, want the DMA's 0x%02X", got, i+2, i+1)
+			}
+			if got := mem.Read(0xFF80); got != mem.hram[0] {
+				t.Fatal("DMA blocked HRAM")
+			}
+		}
+	}
+
+	if mem.OAMDMAActive() || mem.dmaBlocks(0xC000) {
+		t.Error("transfer still running after 160 bytes")
+	}
+	if got := mem.Read(0xC010); got != 0x11 {
+		t.Errorf("CPU read 0x%02X after the transfer, want WRAM's 0x11", got)
+	}
+}
@@ -0,0 +1,199 @@
+package memory
+
+// Registers the built-in devices give side effects to
+const (
+	addrLY   = 0xFF44
+	addrNR52 = 0xFF26
+)
+
+// registerFile is a device that holds whatever is written to it, for
+// registers whose hardware isn't emulated. It is backed by the I/O region
+// of Memory, where the post-boot values are set.
+type registerFile struct {
+	io []byte
+}
+
+func (r registerFile) ReadRegister(addr uint16) byte {
+	return r.io[addr-0xFF00]
+}
+
+func (r registerFile) WriteRegister(addr uint16, value byte) {
+	r.io[addr-0xFF00] = value
+}
+
+// deviceFuncs adapts a pair of functions to Device, for registers Memory
+// implements itself
+type deviceFuncs struct {
+	read  func(addr uint16) byte
+	write func(addr uint16, value byte)
+}
+
+func (d deviceFuncs) ReadRegister(addr uint16) byte {
+	return d.read(addr)
+}
+
+func (d deviceFuncs) WriteRegister(addr uint16, value byte) {
+	d.write(addr, value)
+}
+
+// joypadRegisters is P1. Only the line select bits are writable, the
+// input lines are driven by the buttons.
+type joypadRegisters struct {
+	registerFile
+}
+
+func (j joypadRegisters) WriteRegister(addr uint16, value byte) {
+	j.io[addr-0xFF00] = j.io[addr-0xFF00]&^0x30 | value&0x30
+}
+
+// timerRegisters are DIV, TIMA, TMA and TAC. Any write to DIV resets it.
+type timerRegisters struct {
+	registerFile
+}
+
+func (t timerRegisters) WriteRegister(addr uint16, value byte) {
+	if addr == addrDIV {
+		value = 0
+	}
+	t.registerFile.WriteRegister(addr, value)
+}
+
+// ppuRegisters are the LCD registers. LY is read only, as are the mode and
+// coincidence bits of STAT.
+type ppuRegisters struct {
+	registerFile
+}
+
+func (p ppuRegisters) WriteRegister(addr uint16, value byte) {
+	switch addr {
+	case addrLY:
+		return
+	case addrSTAT:
+		value = p.io[addr-0xFF00]&0x07 | value&0x78
+	}
+	p.registerFile.WriteRegister(addr, value)
+}
+
+// dmaRegister is the OAM DMA register. It keeps the value written as the
+// source page and starts a transfer from it.
+type dmaRegister struct {
+	registerFile
+	start func(value byte)
+}
+
+func (d dmaRegister) WriteRegister(addr uint16, value byte) {
+	d.registerFile.WriteRegister(addr, value)
+	d.start(value)
+}
+
+// bankRegisters are the CGB's VBK and SVBK. Only the bank number bits are
+// stored, so the rest read as the unused bits of the read mask.
+type bankRegisters struct {
+	registerFile
+}
+
+func (b bankRegisters) WriteRegister(addr uint16, value byte) {
+	switch addr {
+	case addrVBK:
+		value &= 0x01
+	case addrSVBK:
+		value &= 0x07
+	}
+	b.registerFile.WriteRegister(addr, value)
+}
+
+// apuRegisters are the sound registers and wave RAM. Only the power bit of
+// NR52 is writable, the channel status bits are read only. Powering the APU
+// off clears its registers, which then ignore writes until it is powered
+// on again; wave RAM is unaffected.
+type apuRegisters struct {
+	registerFile
+}
+
+func (a apuRegisters) WriteRegister(addr uint16, value byte) {
+	if addr == addrNR52 {
+		if value&0x80 == 0 {
+			for i := 0x10; i < 0x26; i++ {
+				a.io[i] = 0
+			}
+			a.io[addrNR52-0xFF00] = 0
+			return
+		}
+		a.io[addrNR52-0xFF00] = 0x80 | a.io[addrNR52-0xFF00]&0x0F
+		return
+	}
+
+	if a.io[addrNR52-0xFF00]&0x80 == 0 && addr < 0xFF30 {
+		return
+	}
+	a.registerFile.WriteRegister(addr, value)
+}
+
+// apuRegisterMasks are the read masks of the sound registers, which read
+// back write only bits such as triggers and lengths as 1
+var apuRegisterMasks = []Register{
+	{0xFF10, 0x80}, {0xFF11, 0x3F}, {0xFF12, 0x00}, {0xFF13, 0xFF}, {0xFF14, 0xBF}, // NR10-NR14
+	{0xFF16, 0x3F}, {0xFF17, 0x00}, {0xFF18, 0xFF}, {0xFF19, 0xBF}, // NR21-NR24
+	{0xFF1A, 0x7F}, {0xFF1B, 0xFF}, {0xFF1C, 0x9F}, {0xFF1D, 0xFF}, {0xFF1E, 0xBF}, // NR30-NR34
+	{0xFF20, 0xFF}, {0xFF21, 0x00}, {0xFF22, 0x00}, {0xFF23, 0xBF}, // NR41-NR44
+	{0xFF24, 0x00}, {0xFF25, 0x00}, {0xFF26, 0x70}, // NR50-NR52
+}
+
+// registerDevices wires the I/O region to the built-in devices for the
+// model and cartridge
+func (mem *Memory) registerDevices() {
+	io := registerFile{io: mem.io}
+
+	mem.RegisterDevice(joypadRegisters{io}, Register{addrP1, 0xC0})
+
+	// Serial, SC's clock speed bit only exists in CGB mode
+	scMask := byte(0x7E)
+	if mem.cgb {
+		scMask = 0x7C
+	}
+	mem.RegisterDevice(io, Register{0xFF01, 0x00}, Register{0xFF02, scMask})
+
+	mem.RegisterDevice(timerRegisters{io},
+		Register{addrDIV, 0x00}, Register{0xFF05, 0x00}, Register{0xFF06, 0x00}, Register{0xFF07, 0xF8})
+
+	mem.RegisterDevice(io, Register{addrIF, ^byte(interruptMask)})
+
+	mem.RegisterDevice(apuRegisters{io}, apuRegisterMasks...)
+	for addr := uint16(0xFF30); addr < 0xFF40; addr++ {
+		// Wave RAM
+		mem.RegisterDevice(apuRegisters{io}, Register{addr, 0x00})
+	}
+
+	for addr := uint16(0xFF40); addr <= 0xFF4B; addr++ {
+		if addr == addrDMA {
+			continue
+		}
+		readMask := byte(0x00)
+		if addr == addrSTAT {
+			readMask = 0x80
+		}
+		mem.RegisterDevice(ppuRegisters{io}, Register{addr, readMask})
+	}
+
+	mem.RegisterDevice(dmaRegister{io, mem.startOAMDMA}, Register{addrDMA, 0x00})
+	mem.RegisterDevice(deviceFuncs{io.ReadRegister, mem.writeBOOT}, Register{addrBOOT, 0xFF})
+
+	if mem.cgb {
+		mem.RegisterDevice(deviceFuncs{mem.readKEY1, mem.writeKEY1}, Register{addrKEY1, 0x7E})
+		mem.RegisterDevice(bankRegisters{io}, Register{addrVBK, 0xFE}, Register{addrSVBK, 0xF8})
+		mem.RegisterDevice(deviceFuncs{mem.readHDMA, mem.writeHDMA},
+			Register{addrHDMA1, 0xFF}, Register{addrHDMA2, 0xFF}, Register{addrHDMA3, 0xFF},
+			Register{addrHDMA4, 0xFF}, Register{addrHDMA5, 0x00})
+
+		// Infrared port and palettes
+		mem.RegisterDevice(io, Register{0xFF56, 0x3C})
+		mem.RegisterDevice(ppuRegisters{io},
+			Register{0xFF68, 0x40}, Register{0xFF69, 0x00}, Register{0xFF6A, 0x40},
+			Register{0xFF6B, 0x00}, Register{0xFF6C, 0xFE})
+		mem.RegisterDevice(io, Register{0xFF74, 0x00})
+	}
+	if mem.model.IsCGB() {
+		// Undocumented registers, present even outside CGB mode
+		mem.RegisterDevice(io, Register{0xFF72, 0x00}, Register{0xFF73, 0x00}, Register{0xFF75, 0x8F})
+	}
+}
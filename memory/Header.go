@@ -0,0 +1,218 @@
+package memory
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Header field offsets, 0x0100-0x014F of every ROM
+const (
+	addrLogo           = 0x104
+	addrTitle          = 0x134
+	addrManufacturer   = 0x13F
+	addrCGBFlag        = 0x143
+	addrNewLicensee    = 0x144
+	addrSGBFlag        = 0x146
+	addrCartridgeType  = 0x147
+	addrROMSize        = 0x148
+	addrRAMSize        = 0x149
+	addrDestination    = 0x14A
+	addrOldLicensee    = 0x14B
+	addrMaskROMVersion = 0x14C
+	addrHeaderChecksum = 0x14D
+	addrGlobalChecksum = 0x14E
+
+	headerEnd = 0x150
+
+	// useNewLicensee in the old licensee byte means the code is in the new
+	// licensee field. Only carts with it can use SGB features.
+	useNewLicensee = 0x33
+)
+
+// nintendoLogo is the bitmap every licensed cartridge carries at
+// 0x0104-0x0133, which the boot ROM checks before starting it
+var nintendoLogo = []byte{
+	0xCE, 0xED, 0x66, 0x66, 0xCC, 0x0D, 0x00, 0x0B, 0x03, 0x73, 0x00, 0x83, 0x00, 0x0C, 0x00, 0x0D,
+	0x00, 0x08, 0x11, 0x1F, 0x88, 0x89, 0x00, 0x0E, 0xDC, 0xCC, 0x6E, 0xE6, 0xDD, 0xDD, 0xD9, 0x99,
+	0xBB, 0xBB, 0x67, 0x63, 0x6E, 0x0E, 0xEC, 0xCC, 0xDD, 0xDC, 0x99, 0x9F, 0xBB, 0xB9, 0x33, 0x3E,
+}
+
+// CGBSupport is how a cartridge declares Game Boy Color support
+type CGBSupport byte
+
+const (
+	// CGBNone is a DMG cartridge
+	CGBNone CGBSupport = 0x00
+	// CGBEnhanced uses CGB features but still runs on a DMG
+	CGBEnhanced CGBSupport = 0x80
+	// CGBOnly runs on a CGB only
+	CGBOnly CGBSupport = 0xC0
+)
+
+func (support CGBSupport) String() string {
+	switch support {
+	case CGBEnhanced:
+		return "CGB enhanced"
+	case CGBOnly:
+		return "CGB only"
+	}
+	return "DMG"
+}
+
+// Destination is the market a cartridge was sold in
+type Destination byte
+
+const (
+	DestinationJapan    Destination = 0x00
+	DestinationOverseas Destination = 0x01
+)
+
+func (dest Destination) String() string {
+	switch dest {
+	case DestinationJapan:
+		return "Japan"
+	case DestinationOverseas:
+		return "Overseas"
+	}
+	return fmt.Sprintf("Unknown (0x%02X)", byte(dest))
+}
+
+// Header is the cartridge header at 0x0100-0x014F, as the ROM declares it
+type Header struct {
+	Title string
+	// Manufacturer is the 4 character code of later cartridges, or empty
+	Manufacturer  string
+	CGB           CGBSupport
+	SGB           bool
+	CartridgeType byte
+	ROMSize       int // Bytes
+	RAMSize       int // Bytes
+	Destination   Destination
+	// Licensee is the publisher code, two characters for the new licensee
+	// field or two hex digits for the old one
+	Licensee       string
+	LicenseeName   string
+	MaskROMVersion byte
+	HeaderChecksum byte
+	GlobalChecksum uint16
+}
+
+// parseHeader reads the header of a ROM at least headerEnd bytes long
+func parseHeader(data []byte) Header {
+	header := Header{
+		CGB:            parseCGBSupport(data[addrCGBFlag]),
+		CartridgeType:  data[addrCartridgeType],
+		ROMSize:        getROMSize(data[addrROMSize]),
+		RAMSize:        getRAMSize(data[addrRAMSize]),
+		Destination:    Destination(data[addrDestination]),
+		MaskROMVersion: data[addrMaskROMVersion],
+		HeaderChecksum: data[addrHeaderChecksum],
+		GlobalChecksum: uint16(data[addrGlobalChecksum])<<8 | uint16(data[addrGlobalChecksum+1]),
+	}
+
+	header.Title, header.Manufacturer = parseTitle(data)
+
+	if data[addrOldLicensee] == useNewLicensee {
+		header.Licensee = string(data[addrNewLicensee : addrNewLicensee+2])
+		header.LicenseeName = newLicensees[header.Licensee]
+		// SGB functions are only unlocked alongside a new licensee code
+		header.SGB = data[addrSGBFlag] == 0x03
+	} else {
+		header.Licensee = fmt.Sprintf("%02X", data[addrOldLicensee])
+		header.LicenseeName = oldLicensees[data[addrOldLicensee]]
+	}
+
+	return header
+}
+
+func parseCGBSupport(flag byte) CGBSupport {
+	// Only bit 7 is checked by the hardware, bit 6 marks CGB only carts
+	switch {
+	case flag&0xC0 == 0xC0:
+		return CGBOnly
+	case flag&0x80 != 0:
+		return CGBEnhanced
+	}
+	return CGBNone
+}
+
+// parseTitle returns the title and manufacturer code. The title field was
+// 16 bytes on DMG cartridges and shrank to 15 on CGB ones to make room for
+// the CGB flag, then to 11 on later ones to make room for the
+// manufacturer code. Since nothing marks which layout is used, a CGB
+// cartridge whose last 4 title characters are all uppercase letters or
+// digits is taken to have a manufacturer code.
+func parseTitle(data []byte) (title string, manufacturer string) {
+	titleEnd := addrCGBFlag + 1
+	if data[addrCGBFlag]&0x80 != 0 {
+		titleEnd = addrCGBFlag
+		code := data[addrManufacturer:addrCGBFlag]
+		if isManufacturerCode(code) {
+			titleEnd = addrManufacturer
+			manufacturer = string(code)
+		}
+	}
+
+	titleBytes := data[addrTitle:titleEnd]
+	// The title is padded with NULs, or spaces on some carts
+	if end := strings.IndexByte(string(titleBytes), 0); end >= 0 {
+		titleBytes = titleBytes[:end]
+	}
+	return strings.TrimRight(string(titleBytes), " "), manufacturer
+}
+
+func isManufacturerCode(code []byte) bool {
+	for _, c := range code {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// isValidHeader reports whether data starts with a header the boot ROM
+// would accept, with the Nintendo logo and a correct header checksum
+func isValidHeader(data []byte) bool {
+	return len(data) >= headerEnd &&
+		bytes.Equal(data[addrLogo:addrTitle], nintendoLogo) &&
+		computeHeaderChecksum(data) == data[addrHeaderChecksum]
+}
+
+// computeHeaderChecksum returns the checksum of 0x0134-0x014C, which the
+// boot ROM checks before starting the cartridge
+func computeHeaderChecksum(data []byte) byte {
+	checksum := byte(0)
+	for _, b := range data[addrTitle:addrHeaderChecksum] {
+		checksum = checksum - b - 1
+	}
+	return checksum
+}
+
+// computeGlobalChecksum returns the sum of every ROM byte except the
+// global checksum itself. Nothing on the hardware checks it.
+func computeGlobalChecksum(data []byte) uint16 {
+	checksum := uint16(0)
+	for i, b := range data {
+		if i != addrGlobalChecksum && i != addrGlobalChecksum+1 {
+			checksum += uint16(b)
+		}
+	}
+	return checksum
+}
+
+func getROMSize(code byte) int {
+	romSizes := []int{32 * 1024, 64 * 1024, 128 * 1024, 256 * 1024, 512 * 1024, 1024 * 1024, 2048 * 1024, 4096 * 1024, 8192 * 1024}
+	if int(code) < len(romSizes) {
+		return romSizes[code]
+	}
+	return 0
+}
+
+func getRAMSize(code byte) int {
+	ramSizes := []int{0, 2048, 8192, 32768, 131072, 65536}
+	if int(code) < len(ramSizes) {
+		return ramSizes[code]
+	}
+	return 0
+}
@@ -0,0 +1,111 @@
+package memory
+
+// Infrared is the other end of a cartridge's infrared port, such as a
+// link to another emulator instance or a TV remote
+type Infrared interface {
+	// SetLED is called when the cartridge switches its IR LED on or off
+	SetLED(on bool)
+	// Receiving reports whether the cartridge's sensor sees IR light
+	Receiving() bool
+}
+
+// infraredCartridge is implemented by controllers with an IR port
+type infraredCartridge interface {
+	setInfrared(ir Infrared)
+}
+
+// huc1 is Hudson's HuC1 controller, an MBC1 work-alike with an infrared
+// LED and sensor that can be mapped in place of RAM.
+type huc1 struct {
+	cartMemory
+	irMode   bool // 0xA000-0xBFFF accesses the IR port instead of RAM
+	romBank  byte
+	ramBank  byte
+	ledOn    bool
+	infrared Infrared
+}
+
+func newHuC1(banks cartMemory) *huc1 {
+	return &huc1{cartMemory: banks, romBank: 1}
+}
+
+func (mbc *huc1) ReadROM(addr uint16) byte {
+	if addr < 0x4000 {
+		return mbc.romByte(0, addr)
+	}
+	return mbc.romByte(int(mbc.romBank), addr)
+}
+
+func (mbc *huc1) ReadRAM(addr uint16) byte {
+	if mbc.irMode {
+		return readInfrared(mbc.infrared)
+	}
+	// RAM is readable whatever the mode register says
+	return mbc.ramByte(int(mbc.ramBank), addr)
+}
+
+func (mbc *huc1) WriteRAM(addr uint16, value byte) {
+	if mbc.irMode {
+		mbc.ledOn = writeInfrared(mbc.infrared, mbc.ledOn, value)
+		return
+	}
+	mbc.setRAMByte(int(mbc.ramBank), addr, value)
+}
+
+func (mbc *huc1) WriteControl(addr uint16, value byte) {
+	if addr < 0x2000 {
+		// 0x0E maps the IR port, anything else RAM
+		mbc.irMode = value == 0x0E
+	} else if addr < 0x4000 {
+		// ROM bank number
+		mbc.romBank = value & 0x3F
+	} else if addr < 0x6000 {
+		// RAM bank number
+		mbc.ramBank = value & 0x03
+	}
+}
+
+func (mbc *huc1) setInfrared(ir Infrared) {
+	mbc.infrared = ir
+}
+
+func (mbc *huc1) MarshalBinary() ([]byte, error) {
+	return mbc.marshalState(
+		boolToByte(mbc.irMode),
+		mbc.romBank,
+		mbc.ramBank,
+		boolToByte(mbc.ledOn),
+	), nil
+}
+
+func (mbc *huc1) UnmarshalBinary(data []byte) error {
+	registers, err := mbc.unmarshalState(data, 4)
+	if err != nil {
+		return err
+	}
+
+	mbc.irMode = registers[0] != 0
+	mbc.romBank = registers[1]
+	mbc.ramBank = registers[2]
+	mbc.ledOn = writeInfrared(mbc.infrared, mbc.ledOn, registers[3])
+	return nil
+}
+
+// readInfrared returns the IR register of Hudson's controllers, with bit 0
+// set while light is being received
+func readInfrared(ir Infrared) byte {
+	if ir != nil && ir.Receiving() {
+		return 0xC1
+	}
+	return 0xC0
+}
+
+// writeInfrared drives the IR LED from bit 0 of value, telling the other
+// end only when the LED changes, and returns the new LED state
+func writeInfrared(ir Infrared, ledOn bool, value byte) bool {
+	on := value&0x01 != 0
+	if on != ledOn && ir != nil {
+		ir.SetLED(on)
+	}
+	return on
+}
@@ -0,0 +1,293 @@
+package memory
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// HuC3 clock limits, minutes within a day and a 12-bit day counter
+const (
+	huc3MinutesPerDay = 24 * 60
+	huc3Days          = 4096
+)
+
+// huc3 is Hudson's HuC3 controller. Besides banking it has an infrared
+// port and a clock chip with 256 nibbles of memory, driven through a
+// command register and a semaphore mapped in place of RAM.
+type huc3 struct {
+	cartMemory
+	mode     byte // 0xA000-0xBFFF function, written to 0x0000-0x1FFF
+	romBank  byte
+	ramBank  byte
+	ledOn    bool
+	infrared Infrared
+
+	command  byte      // Command waiting for the semaphore
+	response byte      // Result of the last command
+	address  byte      // Clock memory access address
+	rtcMem   [256]byte // Clock memory, one nibble per byte
+	clock    huc3Clock
+}
+
+func newHuC3(banks cartMemory) *huc3 {
+	return &huc3{cartMemory: banks, romBank: 1, clock: huc3Clock{lastSync: time.Now()}}
+}
+
+func (mbc *huc3) ReadROM(addr uint16) byte {
+	if addr < 0x4000 {
+		return mbc.romByte(0, addr)
+	}
+	return mbc.romByte(int(mbc.romBank), addr)
+}
+
+func (mbc *huc3) ReadRAM(addr uint16) byte {
+	switch mbc.mode {
+	case 0x00, 0x0A:
+		return mbc.ramByte(int(mbc.ramBank), addr)
+	case 0x0C:
+		// Command response
+		return mbc.response
+	case 0x0D:
+		// Semaphore, commands finish immediately so it always reads ready
+		return 0xFF
+	case 0x0E:
+		return readInfrared(mbc.infrared)
+	}
+	return 0xFF
+}
+
+func (mbc *huc3) WriteRAM(addr uint16, value byte) {
+	switch mbc.mode {
+	case 0x0A:
+		mbc.setRAMByte(int(mbc.ramBank), addr, value)
+	case 0x0B:
+		// Command and argument, run when the semaphore is cleared
+		mbc.command = value & 0x7F
+	case 0x0D:
+		if value&0x01 == 0 {
+			mbc.execute()
+		}
+	case 0x0E:
+		mbc.ledOn = writeInfrared(mbc.infrared, mbc.ledOn, value)
+	}
+}
+
+// execute runs the pending clock command. The upper nibble selects the
+// command and the lower one is its argument.
+func (mbc *huc3) execute() {
+	command := mbc.command >> 4
+	argument := mbc.command & 0x0F
+	result := byte(0)
+
+	switch command {
+	case 0x1:
+		// Read and increment the address
+		result = mbc.rtcMem[mbc.address]
+		mbc.address++
+	case 0x3:
+		// Write and increment the address
+		mbc.rtcMem[mbc.address] = argument
+		mbc.address++
+	case 0x4:
+		// Address low nibble
+		mbc.address = mbc.address&0xF0 | argument
+	case 0x5:
+		// Address high nibble
+		mbc.address = mbc.address&0x0F | argument<<4
+	case 0x6:
+		switch argument {
+		case 0x0:
+			// Copy the current time into clock memory
+			mbc.clock.sync()
+			minutes, days := mbc.clock.time()
+			putNibbles(mbc.rtcMem[0:3], minutes)
+			putNibbles(mbc.rtcMem[3:6], days)
+		case 0x1:
+			// Set the time from clock memory
+			mbc.clock.set(nibbles(mbc.rtcMem[0:3]), nibbles(mbc.rtcMem[3:6]))
+		case 0x2:
+			// Status check, always answers ready. The tone generator
+			// commands aren't emulated.
+			result = 0x01
+		}
+	}
+
+	mbc.response = 0x80 | command<<4 | result
+}
+
+// putNibbles stores value into a run of nibbles, least significant first
+func putNibbles(digits []byte, value int) {
+	for i := range digits {
+		digits[i] = byte(value>>(4*i)) & 0x0F
+	}
+}
+
+// nibbles reads a value stored by putNibbles
+func nibbles(digits []byte) int {
+	value := 0
+	for i, digit := range digits {
+		value |= int(digit&0x0F) << (4 * i)
+	}
+	return value
+}
+
+func (mbc *huc3) WriteControl(addr uint16, value byte) {
+	if addr < 0x2000 {
+		// Function of 0xA000-0xBFFF
+		mbc.mode = value & 0x0F
+	} else if addr < 0x4000 {
+		// ROM bank number
+		mbc.romBank = value & 0x7F
+	} else if addr < 0x6000 {
+		// RAM bank number
+		mbc.ramBank = value & 0x03
+	}
+}
+
+func (mbc *huc3) tick(rtcTicks int) {
+	mbc.clock.tick(rtcTicks)
+}
+
+func (mbc *huc3) setRTCClock(clock RTCClock) {
+	mbc.clock.setClock(clock)
+}
+
+func (mbc *huc3) setInfrared(ir Infrared) {
+	mbc.infrared = ir
+}
+
+// huc3StateSize is the controller registers, the clock memory, then the
+// clock's seconds count, emulated tick counter and wall clock reference
+const huc3StateSize = 7 + 256 + 8 + 8 + 8
+
+func (mbc *huc3) MarshalBinary() ([]byte, error) {
+	mbc.clock.sync()
+
+	state := make([]byte, huc3StateSize)
+	state[0] = mbc.mode
+	state[1] = mbc.romBank
+	state[2] = mbc.ramBank
+	state[3] = boolToByte(mbc.ledOn)
+	state[4] = mbc.command
+	state[5] = mbc.response
+	state[6] = mbc.address
+	copy(state[7:263], mbc.rtcMem[:])
+	binary.LittleEndian.PutUint64(state[263:271], uint64(mbc.clock.seconds))
+	binary.LittleEndian.PutUint64(state[271:279], uint64(mbc.clock.ticks))
+	binary.LittleEndian.PutUint64(state[279:287], uint64(mbc.clock.lastSync.UnixNano()))
+	return mbc.marshalState(state...), nil
+}
+
+func (mbc *huc3) UnmarshalBinary(data []byte) error {
+	state, err := mbc.unmarshalState(data, huc3StateSize)
+	if err != nil {
+		return err
+	}
+
+	mbc.mode = state[0]
+	mbc.romBank = state[1]
+	mbc.ramBank = state[2]
+	mbc.ledOn = writeInfrared(mbc.infrared, mbc.ledOn, state[3])
+	mbc.command = state[4]
+	mbc.response = state[5]
+	mbc.address = state[6]
+	copy(mbc.rtcMem[:], state[7:263])
+	mbc.clock.seconds = int64(binary.LittleEndian.Uint64(state[263:271]))
+	mbc.clock.ticks = int64(binary.LittleEndian.Uint64(state[271:279]))
+	mbc.clock.lastSync = time.Unix(0, int64(binary.LittleEndian.Uint64(state[279:287])))
+	return nil
+}
+
+// huc3FooterSize is the clock appended to HuC3 save files: the seconds
+// count, then the Unix time the file was written. There is no common
+// format for this, so other emulators may not keep the time.
+const huc3FooterSize = 16
+
+func (mbc *huc3) saveData() []byte {
+	mbc.clock.sync()
+	footer := make([]byte, huc3FooterSize)
+	binary.LittleEndian.PutUint64(footer[0:8], uint64(mbc.clock.seconds))
+	binary.LittleEndian.PutUint64(footer[8:16], uint64(time.Now().Unix()))
+	return append(mbc.cartMemory.saveData(), footer...)
+}
+
+func (mbc *huc3) loadSaveData(data []byte) error {
+	if len(data) != len(mbc.ram)+huc3FooterSize {
+		return mbc.cartMemory.loadSaveData(data)
+	}
+
+	footer := data[len(mbc.ram):]
+	if err := mbc.cartMemory.loadSaveData(data[:len(mbc.ram)]); err != nil {
+		return err
+	}
+	mbc.clock.seconds = int64(binary.LittleEndian.Uint64(footer[0:8]))
+	mbc.clock.ticks = 0
+	mbc.clock.lastSync = time.Now()
+
+	saved := time.Unix(int64(binary.LittleEndian.Uint64(footer[8:16])), 0)
+	if mbc.clock.clock == RTCWallClock && saved.Before(time.Now()) {
+		mbc.clock.lastSync = saved
+		mbc.clock.sync()
+	}
+	return nil
+}
+
+// huc3Clock counts the minutes and days of the HuC3 clock chip. It keeps
+// seconds internally so the minute rolls over on time.
+type huc3Clock struct {
+	seconds int64 // Since day 0, minute 0
+
+	clock    RTCClock
+	ticks    int64     // Emulated clock, ticks towards the next second
+	lastSync time.Time // Wall clock, time seconds was last brought up to date
+}
+
+func (c *huc3Clock) setClock(clock RTCClock) {
+	c.sync()
+	c.clock = clock
+	c.ticks = 0
+	c.lastSync = time.Now()
+}
+
+// tick advances the emulated clock
+func (c *huc3Clock) tick(ticks int) {
+	if c.clock != RTCEmulatedClock {
+		return
+	}
+
+	c.ticks += int64(ticks)
+	if c.ticks >= rtcTicksPerSecond {
+		c.advance(c.ticks / rtcTicksPerSecond)
+		c.ticks %= rtcTicksPerSecond
+	}
+}
+
+// sync brings the wall clock driven count up to date
+func (c *huc3Clock) sync() {
+	if c.clock != RTCWallClock {
+		return
+	}
+
+	elapsed := int64(time.Since(c.lastSync) / time.Second)
+	if elapsed > 0 {
+		c.advance(elapsed)
+		c.lastSync = c.lastSync.Add(time.Duration(elapsed) * time.Second)
+	}
+}
+
+func (c *huc3Clock) advance(seconds int64) {
+	c.seconds = (c.seconds + seconds) % (huc3Days * huc3MinutesPerDay * 60)
+}
+
+// time returns the minute of the day and the day counter
+func (c *huc3Clock) time() (minutes int, days int) {
+	totalMinutes := int(c.seconds / 60)
+	return totalMinutes % huc3MinutesPerDay, totalMinutes / huc3MinutesPerDay
+}
+
+func (c *huc3Clock) set(minutes int, days int) {
+	c.sync()
+	c.seconds = int64((days%huc3Days)*huc3MinutesPerDay+minutes%huc3MinutesPerDay) * 60
+	c.ticks = 0
+	c.lastSync = time.Now()
+}
@@ -0,0 +1,106 @@
+package memory
+
+import "fmt"
+
+// Registers with side effects, or that the CPU itself needs to reach into
+// for STOP and the CGB double speed switch.
+const (
+	addrP1   = 0xFF00
+	addrDIV  = 0xFF04
+	addrSTAT = 0xFF41
+	addrKEY1 = 0xFF4D
+)
+
+// ResetDIV clears the divider register, as any write to it or STOP does
+func (mem *Memory) ResetDIV() {
+	mem.writeIO(addrDIV, 0)
+}
+
+// JoypadInput reports whether any of the selected P1 input lines is pulled
+// low, which is what brings the CPU out of STOP
+func (mem *Memory) JoypadInput() bool {
+	return mem.readIO(addrP1)&0x0F != 0x0F
+}
+
+// SpeedSwitchArmed reports whether KEY1 has been prepared for a speed switch
+// on the next STOP. Always false on the DMG, which has no KEY1.
+func (mem *Memory) SpeedSwitchArmed() bool {
+	return mem.cgb && mem.readIO(addrKEY1)&0x01 != 0
+}
+
+// SwitchSpeed toggles between normal and double speed mode and disarms KEY1
+func (mem *Memory) SwitchSpeed() {
+	mem.doubleSpeed = !mem.doubleSpeed
+	mem.writeIO(addrKEY1, 0x00)
+}
+
+// DoubleSpeed reports whether the CGB CPU is running at 8.4 MHz
+func (mem *Memory) DoubleSpeed() bool {
+	return mem.doubleSpeed
+}
+
+// readKEY1 returns the prepare bit and, in bit 7, the current speed
+func (mem *Memory) readKEY1(addr uint16) byte {
+	value := boolToByte(mem.speedSwitchArmed)
+	if mem.doubleSpeed {
+		value |= 0x80
+	}
+	return value
+}
+
+// writeKEY1 prepares a speed switch. Only the prepare bit is writable, the
+// speed bit changes on STOP.
+func (mem *Memory) writeKEY1(addr uint16, value byte) {
+	mem.speedSwitchArmed = value&0x01 != 0
+}
+
+// Device is hardware with registers in the I/O region, 0xFF00-0xFF7F,
+// such as the timer, PPU or APU. Memory dispatches reads and writes of the
+// registers a device was registered for to it.
+type Device interface {
+	ReadRegister(addr uint16) byte
+	WriteRegister(addr uint16, value byte)
+}
+
+// Register is an I/O address a device answers to
+type Register struct {
+	Addr uint16
+	// ReadMask has the bits that read as 1 whatever the device returns:
+	// unused bits, and write only ones
+	ReadMask byte
+}
+
+// ioPort is what an I/O address is wired to
+type ioPort struct {
+	device   Device
+	readMask byte
+}
+
+// RegisterDevice wires a device to the given registers, replacing whatever
+// answered there before. Every subsystem starts out with a built-in
+// device that just holds its registers' post-boot values, for an emulated
+// one to take over. Addresses without a device read as 0xFF and ignore
+// writes. Memory goes through the registered device for the registers it
+// uses itself too, such as IF for interrupts and SVBK for WRAM banking.
+func (mem *Memory) RegisterDevice(device Device, registers ...Register) {
+	for _, register := range registers {
+		if register.Addr < 0xFF00 || register.Addr >= 0xFF80 {
+			panic(fmt.Sprintf("register 0x%04X is outside the I/O region", register.Addr))
+		}
+		mem.ports[register.Addr-0xFF00] = ioPort{device: device, readMask: register.ReadMask}
+	}
+}
+
+func (mem *Memory) readIO(addr uint16) byte {
+	port := mem.ports[addr-0xFF00]
+	if port.device == nil {
+		return 0xFF
+	}
+	return port.device.ReadRegister(addr) | port.readMask
+}
+
+func (mem *Memory) writeIO(addr uint16, value byte) {
+	if port := mem.ports[addr-0xFF00]; port.device != nil {
+		port.device.WriteRegister(addr, value)
+	}
+}
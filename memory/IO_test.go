@@ -0,0 +1,139 @@
+package memory
+
+import "testing"
+
+// newTestMemory returns memory for a 32KB ROM-only cartridge with the given
+// CGB flag
+func newTestMemory(t *testing.T, model Model, cgbFlag byte) *Memory {
+	t.Helper()
+
+	rom := make([]byte, 0x8000)
+	rom[addrCGBFlag] = cgbFlag
+	return newTestMemoryFromROM(t, model, rom)
+}
+
+// newTestMemoryFromROM returns memory for a cartridge built from rom
+func newTestMemoryFromROM(t *testing.T, model Model, rom []byte) *Memory {
+	t.Helper()
+
+	cart, err := NewCartridge(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem, err := NewMemoryWithModel(cart, model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mem
+}
+
+// testDevice holds one byte per register and counts the writes to them
+type testDevice struct {
+	values map[uint16]byte
+	writes int
+}
+
+func (d *testDevice) ReadRegister(addr uint16) byte {
+	return d.values[addr]
+}
+
+func (d *testDevice) WriteRegister(addr uint16, value byte) {
+	d.values[addr] = value
+	d.writes++
+}
+
+func TestInterruptsUseRegisteredIF(t *testing.T) {
+	mem := newTestMemory(t, ModelDMG, 0x00)
+	device := &testDevice{values: map[uint16]byte{addrIF: 0x00}}
+	mem.RegisterDevice(device, Register{addrIF, 0xE0})
+	mem.Write(addrIE, 0x1F)
+
+	mem.RequestInterrupt(InterruptTimer)
+	if device.values[addrIF]&0x1F != 0x04 {
+		t.Fatalf("device IF = 0x%02X, want the timer flag set", device.values[addrIF])
+	}
+	if got := mem.Read(addrIF); got != 0xE4 {
+		t.Errorf("IF reads 0x%02X, want 0xE4 with the read mask", got)
+	}
+
+	device.values[addrIF] = 0x01
+	if got := mem.PendingInterrupts(); got != 0x01 {
+		t.Errorf("PendingInterrupts() = 0x%02X, want the device's 0x01", got)
+	}
+
+	mem.ClearInterrupt(InterruptVBlank)
+	if device.values[addrIF]&0x1F != 0 {
+		t.Errorf("device IF = 0x%02X after clearing, want 0", device.values[addrIF])
+	}
+}
+
+func TestBankingUsesRegisteredDevices(t *testing.T) {
+	mem := newTestMemory(t, ModelCGB, 0x80)
+	mem.Write(0xD000, 0x11) // Bank 1
+	mem.Write(addrSVBK, 0x02)
+	mem.Write(0xD000, 0x22)
+	mem.Write(addrVBK, 0x01)
+	mem.Write(0x8000, 0x33)
+
+	device := &testDevice{values: map[uint16]byte{addrSVBK: 0x01, addrVBK: 0x00}}
+	mem.RegisterDevice(device, Register{addrSVBK, 0xF8}, Register{addrVBK, 0xFE})
+	if got := mem.Read(0xD000); got != 0x11 {
+		t.Errorf("0xD000 reads 0x%02X, want bank 1's 0x11", got)
+	}
+	if got := mem.Read(0x8000); got != 0x00 {
+		t.Errorf("0x8000 reads 0x%02X, want bank 0's 0x00", got)
+	}
+
+	device.values[addrSVBK] = 0x02
+	if got := mem.Read(0xD000); got != 0x22 {
+		t.Errorf("0xD000 reads 0x%02X, want bank 2's 0x22", got)
+	}
+}
+
+func TestSpeedSwitchUsesRegisteredKEY1(t *testing.T) {
+	mem := newTestMemory(t, ModelCGB, 0x80)
+	device := &testDevice{values: map[uint16]byte{addrKEY1: 0x01}}
+	mem.RegisterDevice(device, Register{addrKEY1, 0x7E})
+
+	if !mem.SpeedSwitchArmed() {
+		t.Fatal("SpeedSwitchArmed() = false with the device's prepare bit set")
+	}
+	mem.SwitchSpeed()
+	if !mem.DoubleSpeed() {
+		t.Error("DoubleSpeed() = false after the switch")
+	}
+	if device.writes != 1 || device.values[addrKEY1] != 0x00 {
+		t.Errorf("device KEY1 = 0x%02X after %d writes, want it disarmed", device.values[addrKEY1], device.writes)
+	}
+}
+
+func TestDMAUsesRegisteredDevice(t *testing.T) {
+	mem := newTestMemory(t, ModelDMG, 0x00)
+	device := &testDevice{values: map[uint16]byte{}}
+	mem.RegisterDevice(device, Register{addrDMA, 0x00})
+
+	mem.Write(addrDMA, 0xC0)
+	if mem.OAMDMAActive() {
+		t.Error("OAM DMA started with another device registered at 0xFF46")
+	}
+	if device.values[addrDMA] != 0xC0 {
+		t.Errorf("device DMA = 0x%02X, want 0xC0", device.values[addrDMA])
+	}
+}
+
+func TestBuiltInCGBRegisterMasks(t *testing.T) {
+	mem := newTestMemory(t, ModelCGB, 0x80)
+	mem.Write(addrVBK, 0xFF)
+	mem.Write(addrSVBK, 0xFF)
+	mem.Write(addrKEY1, 0xFF)
+
+	tests := map[uint16]byte{addrVBK: 0xFF, addrSVBK: 0xFF, addrKEY1: 0x7F}
+	for addr, want := range tests {
+		if got := mem.Read(addr); got != want {
+			t.Errorf("0x%04X reads 0x%02X, want 0x%02X", addr, got, want)
+		}
+	}
+	if mem.wramBank() != 7 || mem.vramBank() != 1 {
+		t.Errorf("banks are WRAM %d and VRAM %d, want 7 and 1", mem.wramBank(), mem.vramBank())
+	}
+}
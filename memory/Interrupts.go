@@ -0,0 +1,44 @@
+package memory
+
+// Interrupt identifies one of the five interrupt sources. The value is the
+// bit position in the IF (0xFF0F) and IE (0xFFFF) registers, which is also
+// the dispatch priority: lower bits are serviced first.
+type Interrupt uint8
+
+const (
+	InterruptVBlank Interrupt = iota
+	InterruptLCD
+	InterruptTimer
+	InterruptSerial
+	InterruptJoypad
+)
+
+const (
+	addrIF = 0xFF0F
+	addrIE = 0xFFFF
+
+	// Only the low five bits of IF are wired, the rest read back as 1
+	interruptMask = 0x1F
+)
+
+// Vector returns the address the CPU jumps to when servicing the interrupt
+func (i Interrupt) Vector() uint16 {
+	return 0x0040 + uint16(i)*8
+}
+
+// RequestInterrupt raises the interrupt's flag in IF. Components such as the
+// PPU, timer, serial port and joypad call this when their condition occurs.
+func (mem *Memory) RequestInterrupt(i Interrupt) {
+	mem.writeIO(addrIF, mem.readIO(addrIF)|1<<i)
+}
+
+// ClearInterrupt acknowledges the interrupt by clearing its flag in IF
+func (mem *Memory) ClearInterrupt(i Interrupt) {
+	mem.writeIO(addrIF, mem.readIO(addrIF)&^(1<<i))
+}
+
+// PendingInterrupts returns the interrupts that are both requested and
+// enabled, regardless of the CPU's IME flag
+func (mem *Memory) PendingInterrupts() byte {
+	return mem.readIO(addrIF) & mem.ie & interruptMask
+}
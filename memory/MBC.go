@@ -0,0 +1,183 @@
+package memory
+
+import (
+	"encoding"
+	"fmt"
+)
+
+// MBC is a cartridge's memory bank controller. It maps the cartridge ROM
+// into 0x0000-0x7FFF and any cartridge RAM into 0xA000-0xBFFF, and is
+// configured by writes to the ROM area.
+type MBC interface {
+	// ReadROM reads from 0x0000-0x7FFF
+	ReadROM(addr uint16) byte
+	// ReadRAM reads from 0xA000-0xBFFF
+	ReadRAM(addr uint16) byte
+	// WriteControl handles a write to 0x0000-0x7FFF
+	WriteControl(addr uint16, value byte)
+	// WriteRAM handles a write to 0xA000-0xBFFF
+	WriteRAM(addr uint16, value byte)
+
+	// The controller registers and RAM contents, for save states
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// cycleTicker is implemented by controllers with hardware that keeps
+// running alongside the CPU, such as a real-time clock
+type cycleTicker interface {
+	tick(rtcTicks int)
+}
+
+// rtcCartridge is implemented by controllers with a real-time clock
+type rtcCartridge interface {
+	setRTCClock(clock RTCClock)
+}
+
+// rumbleCartridge is implemented by controllers that drive a rumble motor
+type rumbleCartridge interface {
+	setRumbleHandler(handler func(on bool))
+}
+
+// accelerometerCartridge is implemented by controllers with a tilt sensor
+type accelerometerCartridge interface {
+	setAccelerometer(x, y float64)
+}
+
+// newMBC returns the controller for the cartridge's header type
+func newMBC(cart *Cartridge) (MBC, error) {
+	header := cart.hardwareHeader()
+	banks := newCartMemory(cart.rom, header.RAMSize)
+
+	switch header.CartridgeType {
+	case 0x00, 0x08, 0x09:
+		// ROM only, optionally with RAM
+		return &romOnly{cartMemory: banks}, nil
+	case 0x01, 0x02, 0x03:
+		return newMBC1(banks), nil
+	case 0x05, 0x06:
+		return newMBC2(cart.rom), nil
+	case 0x0B, 0x0C, 0x0D:
+		return newMMM01(banks), nil
+	case 0x0F, 0x10:
+		// MBC3 with a real-time clock
+		return newMBC3(banks, true), nil
+	case 0x11, 0x12, 0x13:
+		return newMBC3(banks, false), nil
+	case 0x19, 0x1A, 0x1B:
+		return newMBC5(banks, false), nil
+	case 0x1C, 0x1D, 0x1E:
+		// MBC5 with a rumble motor
+		return newMBC5(banks, true), nil
+	case 0x20:
+		return newMBC6(banks), nil
+	case 0x22:
+		// MBC7 with an accelerometer and EEPROM
+		return newMBC7(cart.rom), nil
+	case 0xFC:
+		return newPocketCamera(banks), nil
+	case 0xFE:
+		return newHuC3(banks), nil
+	case 0xFF:
+		return newHuC1(banks), nil
+	}
+
+	return nil, fmt.Errorf("unsupported MBC type 0x%02X", header.CartridgeType)
+}
+
+// cartMemory is the ROM and RAM behind a controller, addressed in banks
+type cartMemory struct {
+	rom      []byte
+	ram      []byte
+	romBanks int // Number of 16KB ROM banks
+	ramBanks int // Number of 8KB RAM banks, or 1 for a partial 2KB bank
+}
+
+func newCartMemory(rom []byte, ramSize int) cartMemory {
+	return cartMemory{
+		rom:      rom,
+		ram:      make([]byte, ramSize),
+		romBanks: max(1, (len(rom)+0x3FFF)/0x4000),
+		ramBanks: (ramSize + 0x1FFF) / 0x2000,
+	}
+}
+
+// romByte reads addr from a 16KB ROM bank, wrapping bank numbers past the
+// end of the ROM the way the unconnected address lines do
+func (c *cartMemory) romByte(bank int, addr uint16) byte {
+	offset := (bank%c.romBanks)*0x4000 + int(addr&0x3FFF)
+	if offset >= len(c.rom) {
+		return 0xFF
+	}
+	return c.rom[offset]
+}
+
+// ramOffset returns the index into ram for addr in an 8KB RAM bank, or -1
+// when the cartridge has no RAM
+func (c *cartMemory) ramOffset(bank int, addr uint16) int {
+	if len(c.ram) == 0 {
+		return -1
+	}
+	offset := (bank%c.ramBanks)*0x2000 + int(addr&0x1FFF)
+	return offset % len(c.ram)
+}
+
+func (c *cartMemory) ramByte(bank int, addr uint16) byte {
+	offset := c.ramOffset(bank, addr)
+	if offset < 0 {
+		return 0xFF
+	}
+	return c.ram[offset]
+}
+
+func (c *cartMemory) setRAMByte(bank int, addr uint16, value byte) {
+	if offset := c.ramOffset(bank, addr); offset >= 0 {
+		c.ram[offset] = value
+	}
+}
+
+// marshalState appends the RAM contents to a controller's registers
+func (c *cartMemory) marshalState(registers ...byte) []byte {
+	return append(registers, c.ram...)
+}
+
+// unmarshalState restores the RAM contents and returns the registers
+// stored in front of them
+func (c *cartMemory) unmarshalState(data []byte, registerCount int) ([]byte, error) {
+	if len(data) != registerCount+len(c.ram) {
+		return nil, fmt.Errorf("invalid MBC state, expected %d bytes but got %d", registerCount+len(c.ram), len(data))
+	}
+	copy(c.ram, data[registerCount:])
+	return data[:registerCount], nil
+}
+
+// romOnly is a cartridge without a controller. Up to 8KB of RAM may be
+// wired straight to 0xA000-0xBFFF.
+type romOnly struct {
+	cartMemory
+}
+
+func (mbc *romOnly) ReadROM(addr uint16) byte {
+	return mbc.romByte(int(addr>>14), addr)
+}
+
+func (mbc *romOnly) ReadRAM(addr uint16) byte {
+	return mbc.ramByte(0, addr)
+}
+
+func (mbc *romOnly) WriteControl(addr uint16, value byte) {
+	// No controller to talk to
+}
+
+func (mbc *romOnly) WriteRAM(addr uint16, value byte) {
+	mbc.setRAMByte(0, addr, value)
+}
+
+func (mbc *romOnly) MarshalBinary() ([]byte, error) {
+	return mbc.marshalState(), nil
+}
+
+func (mbc *romOnly) UnmarshalBinary(data []byte) error {
+	_, err := mbc.unmarshalState(data, 0)
+	return err
+}
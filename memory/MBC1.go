@@ -0,0 +1,118 @@
+package memory
+
+// mbc1 is the MBC1 controller, for up to 2MB of ROM and 32KB of RAM. Its
+// two bank registers combine into a 7-bit ROM bank number, or the upper
+// one selects the RAM bank in advanced banking mode.
+type mbc1 struct {
+	cartMemory
+	ramEnabled bool
+	bank1      byte // 5-bit ROM bank register, 0x2000-0x3FFF
+	bank2      byte // 2-bit upper ROM / RAM bank register, 0x4000-0x5FFF
+	mode       byte // Banking mode, 0x6000-0x7FFF
+
+	// MBC1M multicarts only wire 4 bits of bank1, so bank2 selects one of
+	// four 256KB games
+	bank1Bits int
+}
+
+func newMBC1(banks cartMemory) *mbc1 {
+	mbc := &mbc1{cartMemory: banks, bank1: 1, bank1Bits: 5}
+	if isMBC1Multicart(banks.rom) {
+		mbc.bank1Bits = 4
+	}
+	return mbc
+}
+
+// isMBC1Multicart detects MBC1M carts by the Nintendo logo that each game's
+// header repeats at the start of every 256KB block. They all ship as 1MB.
+func isMBC1Multicart(rom []byte) bool {
+	if len(rom) != 1024*1024 {
+		return false
+	}
+
+	logo := rom[0x0104:0x0134]
+	for game := 1; game < 4; game++ {
+		header := rom[game*0x40000+0x0104 : game*0x40000+0x0134]
+		if string(header) != string(logo) {
+			return false
+		}
+	}
+	return true
+}
+
+func (mbc *mbc1) ReadROM(addr uint16) byte {
+	if addr < 0x4000 {
+		// In advanced banking mode bank2 also applies to the bank 0 area
+		if mbc.mode == 1 {
+			return mbc.romByte(int(mbc.bank2)<<mbc.bank1Bits, addr)
+		}
+		return mbc.romByte(0, addr)
+	}
+
+	bank1 := int(mbc.bank1) & (1<<mbc.bank1Bits - 1)
+	return mbc.romByte(int(mbc.bank2)<<mbc.bank1Bits|bank1, addr)
+}
+
+func (mbc *mbc1) ramBank() int {
+	if mbc.mode == 1 {
+		return int(mbc.bank2)
+	}
+	return 0
+}
+
+func (mbc *mbc1) ReadRAM(addr uint16) byte {
+	if !mbc.ramEnabled {
+		return 0xFF
+	}
+	return mbc.ramByte(mbc.ramBank(), addr)
+}
+
+func (mbc *mbc1) WriteRAM(addr uint16, value byte) {
+	if mbc.ramEnabled {
+		mbc.setRAMByte(mbc.ramBank(), addr, value)
+	}
+}
+
+func (mbc *mbc1) WriteControl(addr uint16, value byte) {
+	if addr < 0x2000 {
+		// RAM enable
+		mbc.ramEnabled = value&0x0F == 0x0A
+	} else if addr < 0x4000 {
+		// ROM bank number. The zero check looks at all 5 bits, so banks
+		// 0x20, 0x40 and 0x60 can't be selected and map to the next bank.
+		mbc.bank1 = value & 0x1F
+		if mbc.bank1 == 0 {
+			mbc.bank1 = 1
+		}
+	} else if addr < 0x6000 {
+		// Upper ROM bank bits, or RAM bank number
+		mbc.bank2 = value & 0x03
+	} else {
+		// Banking mode select
+		mbc.mode = value & 0x01
+	}
+}
+
+func (mbc *mbc1) MarshalBinary() ([]byte, error) {
+	return mbc.marshalState(boolToByte(mbc.ramEnabled), mbc.bank1, mbc.bank2, mbc.mode), nil
+}
+
+func (mbc *mbc1) UnmarshalBinary(data []byte) error {
+	registers, err := mbc.unmarshalState(data, 4)
+	if err != nil {
+		return err
+	}
+
+	mbc.ramEnabled = registers[0] != 0
+	mbc.bank1 = registers[1]
+	mbc.bank2 = registers[2]
+	mbc.mode = registers[3]
+	return nil
+}
+
+func boolToByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
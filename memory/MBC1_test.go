@@ -0,0 +1,133 @@
+package memory
+
+import "testing"
+
+// newBankedROM returns a ROM of the given number of 16KB banks, each
+// starting with its own bank number, with a header for cartridgeType
+func newBankedROM(banks int, cartridgeType byte, ramSizeCode byte) []byte {
+	rom := make([]byte, banks*0x4000)
+	for bank := range banks {
+		rom[bank*0x4000] = byte(bank)
+	}
+	rom[addrCartridgeType] = cartridgeType
+	rom[addrRAMSize] = ramSizeCode
+	return rom
+}
+
+// newMBC1Memory returns memory for a 2MB MBC1 cartridge with 32KB of RAM
+func newMBC1Memory(t *testing.T) *Memory {
+	t.Helper()
+	return newTestMemoryFromROM(t, ModelDMG, newBankedROM(128, 0x03, 0x03))
+}
+
+func TestMBC1ROMBanking(t *testing.T) {
+	tests := []struct {
+		bank1, bank2 byte
+		mode         byte
+		low, high    byte // Banks mapped at 0x0000 and 0x4000
+	}{
+		{0x00, 0, 0, 0x00, 0x01}, // Bank 0 selects bank 1
+		{0x01, 0, 0, 0x00, 0x01},
+		{0x1F, 0, 0, 0x00, 0x1F},
+		{0xE3, 0, 0, 0x00, 0x03}, // Only 5 bits are wired
+		{0x05, 1, 0, 0x00, 0x25},
+		{0x00, 1, 0, 0x00, 0x21}, // 0x20, 0x40 and 0x60 map to the next bank
+		{0x00, 2, 0, 0x00, 0x41},
+		{0x00, 3, 0, 0x00, 0x61},
+		{0x00, 2, 1, 0x40, 0x41}, // Advanced mode applies bank2 to 0x0000
+		{0x10, 3, 1, 0x60, 0x70},
+		{0x10, 1, 1, 0x20, 0x30},
+	}
+
+	for _, test := range tests {
+		mem := newMBC1Memory(t)
+		mem.Write(0x2000, test.bank1)
+		mem.Write(0x4000, test.bank2)
+		mem.Write(0x6000, test.mode)
+
+		if got := mem.Read(0x0000); got != test.low {
+			t.Errorf("bank1 0x%02X, bank2 %d, mode %d: 0x0000 maps bank 0x%02X, want 0x%02X",
+				test.bank1, test.bank2, test.mode, got, test.low)
+		}
+		if got := mem.Read(0x4000); got != test.high {
+			t.Errorf("bank1 0x%02X, bank2 %d, mode %d: 0x4000 maps bank 0x%02X, want 0x%02X",
+				test.bank1, test.bank2, test.mode, got, test.high)
+		}
+	}
+}
+
+func TestMBC1RAMBanking(t *testing.T) {
+	mem := newMBC1Memory(t)
+	if got := mem.Read(0xA000); got != 0xFF {
+		t.Errorf("disabled RAM reads 0x%02X, want 0xFF", got)
+	}
+
+	mem.Write(0x0000, 0x0A)
+	mem.Write(0x6000, 0x01)
+	for bank := range byte(4) {
+		mem.Write(0x4000, bank)
+		mem.Write(0xA000, 0x10+bank)
+	}
+
+	tests := []struct {
+		bank2, mode byte
+		want        byte
+	}{
+		{0, 1, 0x10},
+		{1, 1, 0x11},
+		{2, 1, 0x12},
+		{3, 1, 0x13},
+		{2, 0, 0x10}, // Simple mode always maps RAM bank 0
+		{3, 0, 0x10},
+	}
+	for _, test := range tests {
+		mem.Write(0x4000, test.bank2)
+		mem.Write(0x6000, test.mode)
+		if got := mem.Read(0xA000); got != test.want {
+			t.Errorf("bank2 %d, mode %d: RAM reads 0x%02X, want 0x%02X", test.bank2, test.mode, got, test.want)
+		}
+	}
+
+	mem.Write(0x0000, 0x00)
+	if got := mem.Read(0xA000); got != 0xFF {
+		t.Errorf("disabled RAM reads 0x%02X, want 0xFF", got)
+	}
+}
+
+func TestMBC1MultiCartBanking(t *testing.T) {
+	rom := newBankedROM(64, 0x01, 0x00)
+	for game := range 4 {
+		copy(rom[game*0x40000+addrLogo:], nintendoLogo)
+	}
+
+	tests := []struct {
+		bank1, bank2 byte
+		mode         byte
+		low, high    byte
+	}{
+		{0x00, 0, 0, 0x00, 0x01},
+		{0x12, 1, 0, 0x00, 0x12}, // bank1 has 4 bits, bank2 picks the game
+		{0x0F, 2, 0, 0x00, 0x2F},
+		{0x10, 1, 0, 0x00, 0x10}, // 0x10 passes the zero check, then maps game bank 0
+		{0x03, 3, 1, 0x30, 0x33},
+	}
+
+	for _, test := range tests {
+		mem := newTestMemoryFromROM(t, ModelDMG, rom)
+		if mbc := mem.mbc.(*mbc1); mbc.bank1Bits != 4 {
+			t.Fatalf("bank1 has %d bits, want MBC1M's 4", mbc.bank1Bits)
+		}
+		mem.Write(0x2000, test.bank1)
+		mem.Write(0x4000, test.bank2)
+		mem.Write(0x6000, test.mode)
+
+		if got := mem.Read(0x0000); got != test.low {
+			t.Errorf("bank1 0x%02X, bank2 %d, mode %d: 0x0000 maps bank 0x%02X, want 0x%02X",
+				test.bank1, test.bank2, test.mode, got, test.low)
+		}
+		if got := mem.Read(0x4000); got != test.high {
+			t.Errorf("bank1 0x%02X, bank2 %d, mode %d: 0x4000 maps bank 0x%02X, want 0x%02X",
+				test.bank1, test.bank2, test.mode, got, test.high)
+		}
+	}
+}
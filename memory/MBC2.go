@@ -0,0 +1,72 @@
+package memory
+
+// mbc2RAMSize is the MBC2's built-in RAM, 512 half-bytes
+const mbc2RAMSize = 512
+
+// mbc2 is the MBC2 controller, for up to 256KB of ROM, with 512x4 bits of
+// RAM built into the controller itself.
+type mbc2 struct {
+	cartMemory
+	ramEnabled bool
+	romBank    byte
+}
+
+func newMBC2(rom []byte) *mbc2 {
+	// The header declares no RAM, the controller brings its own
+	return &mbc2{cartMemory: newCartMemory(rom, mbc2RAMSize), romBank: 1}
+}
+
+func (mbc *mbc2) ReadROM(addr uint16) byte {
+	if addr < 0x4000 {
+		return mbc.romByte(0, addr)
+	}
+	return mbc.romByte(int(mbc.romBank), addr)
+}
+
+func (mbc *mbc2) ReadRAM(addr uint16) byte {
+	if !mbc.ramEnabled {
+		return 0xFF
+	}
+	// Only 9 address lines are decoded, so the RAM repeats through
+	// 0xA000-0xBFFF, and the missing upper nibble reads as 1s
+	return mbc.ram[addr&0x01FF] | 0xF0
+}
+
+func (mbc *mbc2) WriteRAM(addr uint16, value byte) {
+	if mbc.ramEnabled {
+		mbc.ram[addr&0x01FF] = value & 0x0F
+	}
+}
+
+func (mbc *mbc2) WriteControl(addr uint16, value byte) {
+	if addr >= 0x4000 {
+		return
+	}
+
+	// Bit 8 of the address picks the register
+	if addr&0x0100 == 0 {
+		// RAM enable
+		mbc.ramEnabled = value&0x0F == 0x0A
+	} else {
+		// ROM bank number, 0 selects bank 1
+		mbc.romBank = value & 0x0F
+		if mbc.romBank == 0 {
+			mbc.romBank = 1
+		}
+	}
+}
+
+func (mbc *mbc2) MarshalBinary() ([]byte, error) {
+	return mbc.marshalState(boolToByte(mbc.ramEnabled), mbc.romBank), nil
+}
+
+func (mbc *mbc2) UnmarshalBinary(data []byte) error {
+	registers, err := mbc.unmarshalState(data, 2)
+	if err != nil {
+		return err
+	}
+
+	mbc.ramEnabled = registers[0] != 0
+	mbc.romBank = registers[1]
+	return nil
+}
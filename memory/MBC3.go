@@ -0,0 +1,197 @@
+package memory
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// mbc3 is the MBC3 controller, for up to 2MB of ROM and 32KB of RAM, with
+// an optional real-time clock whose registers are banked in place of RAM.
+type mbc3 struct {
+	cartMemory
+	ramEnabled bool // Also enables access to the clock
+	romBank    byte
+	ramBank    byte // 0x00-0x07 select RAM, 0x08-0x0C the clock registers
+	latchValue byte // Last write to the latch register
+	rtc        *rtc // nil for carts without a clock
+}
+
+func newMBC3(banks cartMemory, hasRTC bool) *mbc3 {
+	mbc := &mbc3{cartMemory: banks, romBank: 1, latchValue: 0xFF}
+	if hasRTC {
+		mbc.rtc = newRTC()
+	}
+	return mbc
+}
+
+func (mbc *mbc3) ReadROM(addr uint16) byte {
+	if addr < 0x4000 {
+		return mbc.romByte(0, addr)
+	}
+	return mbc.romByte(int(mbc.romBank), addr)
+}
+
+func (mbc *mbc3) ReadRAM(addr uint16) byte {
+	if !mbc.ramEnabled {
+		return 0xFF
+	}
+
+	if mbc.ramBank >= 0x08 {
+		if mbc.rtc == nil || mbc.ramBank > 0x0C {
+			return 0xFF
+		}
+		return mbc.rtc.read(mbc.ramBank)
+	}
+	return mbc.ramByte(int(mbc.ramBank), addr)
+}
+
+func (mbc *mbc3) WriteRAM(addr uint16, value byte) {
+	if !mbc.ramEnabled {
+		return
+	}
+
+	if mbc.ramBank >= 0x08 {
+		if mbc.rtc != nil && mbc.ramBank <= 0x0C {
+			mbc.rtc.write(mbc.ramBank, value)
+		}
+		return
+	}
+	mbc.setRAMByte(int(mbc.ramBank), addr, value)
+}
+
+func (mbc *mbc3) WriteControl(addr uint16, value byte) {
+	if addr < 0x2000 {
+		// RAM and clock enable
+		mbc.ramEnabled = value&0x0F == 0x0A
+	} else if addr < 0x4000 {
+		// ROM bank number, 0 selects bank 1
+		mbc.romBank = value & 0x7F
+		if mbc.romBank == 0 {
+			mbc.romBank = 1
+		}
+	} else if addr < 0x6000 {
+		// RAM bank number or clock register select
+		mbc.ramBank = value & 0x0F
+	} else {
+		// Writing 0x00 then 0x01 latches the clock
+		if mbc.rtc != nil && mbc.latchValue == 0x00 && value == 0x01 {
+			mbc.rtc.latch()
+		}
+		mbc.latchValue = value
+	}
+}
+
+func (mbc *mbc3) tick(rtcTicks int) {
+	if mbc.rtc != nil {
+		mbc.rtc.tick(rtcTicks)
+	}
+}
+
+func (mbc *mbc3) setRTCClock(clock RTCClock) {
+	if mbc.rtc != nil {
+		mbc.rtc.setClock(clock)
+	}
+}
+
+// mbc3StateSize is the controller registers followed by the clock:
+// live and latched registers, halt and carry, the emulated tick counter
+// and the wall clock reference as Unix nanoseconds
+const mbc3StateSize = 4 + 1 + 5 + 5 + 8 + 8
+
+func (mbc *mbc3) MarshalBinary() ([]byte, error) {
+	state := make([]byte, mbc3StateSize)
+	state[0] = boolToByte(mbc.ramEnabled)
+	state[1] = mbc.romBank
+	state[2] = mbc.ramBank
+	state[3] = mbc.latchValue
+
+	if mbc.rtc != nil {
+		mbc.rtc.sync()
+		state[4] = 1
+		live := mbc.rtc.registers()
+		copy(state[5:10], live[:])
+		copy(state[10:15], mbc.rtc.latched[:])
+		binary.LittleEndian.PutUint64(state[15:23], uint64(mbc.rtc.ticks))
+		binary.LittleEndian.PutUint64(state[23:31], uint64(mbc.rtc.lastSync.UnixNano()))
+	}
+	return mbc.marshalState(state...), nil
+}
+
+func (mbc *mbc3) UnmarshalBinary(data []byte) error {
+	state, err := mbc.unmarshalState(data, mbc3StateSize)
+	if err != nil {
+		return err
+	}
+
+	mbc.ramEnabled = state[0] != 0
+	mbc.romBank = state[1]
+	mbc.ramBank = state[2]
+	mbc.latchValue = state[3]
+
+	if mbc.rtc != nil && state[4] != 0 {
+		for i, value := range state[5:10] {
+			mbc.rtc.write(0x08+byte(i), value)
+		}
+		copy(mbc.rtc.latched[:], state[10:15])
+		mbc.rtc.ticks = int64(binary.LittleEndian.Uint64(state[15:23]))
+		mbc.rtc.lastSync = time.Unix(0, int64(binary.LittleEndian.Uint64(state[23:31])))
+	}
+	return nil
+}
+
+// The clock footer most emulators append to MBC3 save files: the live and
+// latched registers as 32-bit values, then the Unix time the file was
+// written. Some older files only have a 32-bit timestamp.
+const (
+	rtcFooterSize      = 48
+	rtcShortFooterSize = 44
+)
+
+func (mbc *mbc3) saveData() []byte {
+	data := mbc.cartMemory.saveData()
+	if mbc.rtc == nil {
+		return data
+	}
+
+	mbc.rtc.sync()
+	footer := make([]byte, rtcFooterSize)
+	live := mbc.rtc.registers()
+	for i := range live {
+		binary.LittleEndian.PutUint32(footer[i*4:], uint32(live[i]))
+		binary.LittleEndian.PutUint32(footer[20+i*4:], uint32(mbc.rtc.latched[i]))
+	}
+	binary.LittleEndian.PutUint64(footer[40:], uint64(time.Now().Unix()))
+	return append(data, footer...)
+}
+
+func (mbc *mbc3) loadSaveData(data []byte) error {
+	footerSize := 0
+	if mbc.rtc != nil {
+		switch len(data) - len(mbc.ram) {
+		case rtcFooterSize, rtcShortFooterSize:
+			footerSize = len(data) - len(mbc.ram)
+		}
+	}
+
+	ramSize := len(data) - footerSize
+	if err := mbc.cartMemory.loadSaveData(data[:ramSize]); err != nil {
+		return err
+	}
+	if footerSize == 0 {
+		// No clock saved, it keeps running from where it is
+		return nil
+	}
+
+	footer := data[ramSize:]
+	for i := range mbc.rtc.latched {
+		mbc.rtc.write(0x08+byte(i), byte(binary.LittleEndian.Uint32(footer[i*4:])))
+		mbc.rtc.latched[i] = byte(binary.LittleEndian.Uint32(footer[20+i*4:]))
+	}
+
+	saved := int64(binary.LittleEndian.Uint32(footer[40:]))
+	if footerSize == rtcFooterSize {
+		saved = int64(binary.LittleEndian.Uint64(footer[40:]))
+	}
+	mbc.rtc.resume(time.Unix(saved, 0))
+	return nil
+}
@@ -0,0 +1,99 @@
+package memory
+
+// mbc5 is the MBC5 controller, for up to 8MB of ROM with 9-bit bank
+// numbers and up to 128KB of RAM in 16 banks. On rumble carts bit 3 of the
+// RAM bank register drives the motor instead.
+type mbc5 struct {
+	cartMemory
+	ramEnabled bool
+	romBank    uint16
+	ramBank    byte
+
+	rumble   bool
+	motorOn  bool
+	onRumble func(on bool)
+}
+
+func newMBC5(banks cartMemory, rumble bool) *mbc5 {
+	return &mbc5{cartMemory: banks, romBank: 1, rumble: rumble}
+}
+
+func (mbc *mbc5) ReadROM(addr uint16) byte {
+	if addr < 0x4000 {
+		return mbc.romByte(0, addr)
+	}
+	// Unlike older controllers, bank 0 can be mapped here too
+	return mbc.romByte(int(mbc.romBank), addr)
+}
+
+func (mbc *mbc5) ReadRAM(addr uint16) byte {
+	if !mbc.ramEnabled {
+		return 0xFF
+	}
+	return mbc.ramByte(int(mbc.ramBank), addr)
+}
+
+func (mbc *mbc5) WriteRAM(addr uint16, value byte) {
+	if mbc.ramEnabled {
+		mbc.setRAMByte(int(mbc.ramBank), addr, value)
+	}
+}
+
+func (mbc *mbc5) WriteControl(addr uint16, value byte) {
+	if addr < 0x2000 {
+		// RAM enable
+		mbc.ramEnabled = value&0x0F == 0x0A
+	} else if addr < 0x3000 {
+		// Low 8 bits of the ROM bank number
+		mbc.romBank = mbc.romBank&0x100 | uint16(value)
+	} else if addr < 0x4000 {
+		// Bit 8 of the ROM bank number
+		mbc.romBank = mbc.romBank&0xFF | uint16(value&0x01)<<8
+	} else if addr < 0x6000 {
+		// RAM bank number
+		if mbc.rumble {
+			mbc.ramBank = value & 0x07
+			mbc.setMotor(value&0x08 != 0)
+		} else {
+			mbc.ramBank = value & 0x0F
+		}
+	}
+}
+
+func (mbc *mbc5) setMotor(on bool) {
+	if on == mbc.motorOn {
+		return
+	}
+
+	mbc.motorOn = on
+	if mbc.onRumble != nil {
+		mbc.onRumble(on)
+	}
+}
+
+func (mbc *mbc5) setRumbleHandler(handler func(on bool)) {
+	mbc.onRumble = handler
+}
+
+func (mbc *mbc5) MarshalBinary() ([]byte, error) {
+	return mbc.marshalState(
+		boolToByte(mbc.ramEnabled),
+		byte(mbc.romBank),
+		byte(mbc.romBank>>8),
+		mbc.ramBank,
+		boolToByte(mbc.motorOn),
+	), nil
+}
+
+func (mbc *mbc5) UnmarshalBinary(data []byte) error {
+	registers, err := mbc.unmarshalState(data, 5)
+	if err != nil {
+		return err
+	}
+
+	mbc.ramEnabled = registers[0] != 0
+	mbc.romBank = uint16(registers[1]) | uint16(registers[2]&0x01)<<8
+	mbc.ramBank = registers[3]
+	mbc.setMotor(registers[4] != 0)
+	return nil
+}
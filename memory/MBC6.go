@@ -0,0 +1,260 @@
+package memory
+
+import "fmt"
+
+// MBC6 flash chip, a Macronix MX29F008 with 1MB in 8KB banks
+const (
+	mbc6FlashSize       = 1024 * 1024
+	mbc6FlashSectorSize = 128 * 1024
+	mbc6FlashMaker      = 0xC2
+	mbc6FlashDevice     = 0x81
+)
+
+// mbc6 is the MBC6 controller used by Net de Get. It splits 0x4000-0x7FFF
+// into two independently banked 8KB windows that can each map ROM or the
+// on-cart flash, and splits RAM into two 4KB windows.
+type mbc6 struct {
+	cartMemory
+	ramEnabled   bool
+	ramBank      [2]byte // 4KB RAM banks at 0xA000 and 0xB000
+	romBank      [2]byte // 8KB banks at 0x4000 and 0x6000
+	flashMapped  [2]bool // Window maps flash instead of ROM
+	flashEnabled bool
+	flashWrite   bool // Flash write enable, needed to program or erase
+	flash        flash
+}
+
+func newMBC6(banks cartMemory) *mbc6 {
+	mbc := &mbc6{cartMemory: banks}
+	mbc.flash.data = make([]byte, mbc6FlashSize)
+	for i := range mbc.flash.data {
+		mbc.flash.data[i] = 0xFF // Erased
+	}
+	return mbc
+}
+
+func (mbc *mbc6) ReadROM(addr uint16) byte {
+	if addr < 0x4000 {
+		return mbc.romByte(0, addr)
+	}
+
+	window := (addr - 0x4000) >> 13
+	offset := int(mbc.romBank[window])*0x2000 + int(addr&0x1FFF)
+	if mbc.flashMapped[window] {
+		if !mbc.flashEnabled {
+			return 0xFF
+		}
+		return mbc.flash.read(offset % mbc6FlashSize)
+	}
+	return mbc.rom[offset%len(mbc.rom)]
+}
+
+func (mbc *mbc6) ramIndex(addr uint16) int {
+	window := (addr - 0xA000) >> 12
+	return (int(mbc.ramBank[window])*0x1000 + int(addr&0x0FFF)) % len(mbc.ram)
+}
+
+func (mbc *mbc6) ReadRAM(addr uint16) byte {
+	if !mbc.ramEnabled || len(mbc.ram) == 0 {
+		return 0xFF
+	}
+	return mbc.ram[mbc.ramIndex(addr)]
+}
+
+func (mbc *mbc6) WriteRAM(addr uint16, value byte) {
+	if mbc.ramEnabled && len(mbc.ram) > 0 {
+		mbc.ram[mbc.ramIndex(addr)] = value
+	}
+}
+
+func (mbc *mbc6) WriteControl(addr uint16, value byte) {
+	switch {
+	case addr < 0x0400:
+		// RAM enable
+		mbc.ramEnabled = value&0x0F == 0x0A
+	case addr < 0x0800:
+		// RAM bank A
+		mbc.ramBank[0] = value & 0x07
+	case addr < 0x0C00:
+		// RAM bank B
+		mbc.ramBank[1] = value & 0x07
+	case addr < 0x1000:
+		// Flash enable
+		mbc.flashEnabled = value&0x01 != 0
+	case addr == 0x1000:
+		// Flash write enable
+		mbc.flashWrite = value&0x01 != 0
+	case addr >= 0x2000 && addr < 0x2800:
+		// ROM/flash bank A
+		mbc.romBank[0] = value & 0x7F
+	case addr >= 0x2800 && addr < 0x3000:
+		// ROM/flash select A
+		mbc.flashMapped[0] = value == 0x08
+	case addr >= 0x3000 && addr < 0x3800:
+		// ROM/flash bank B
+		mbc.romBank[1] = value & 0x7F
+	case addr >= 0x3800 && addr < 0x4000:
+		// ROM/flash select B
+		mbc.flashMapped[1] = value == 0x08
+	case addr >= 0x4000:
+		// Flash command or data, when a window maps the flash
+		window := (addr - 0x4000) >> 13
+		if mbc.flashMapped[window] && mbc.flashEnabled {
+			offset := int(mbc.romBank[window])*0x2000 + int(addr&0x1FFF)
+			mbc.flash.write(offset%mbc6FlashSize, value, mbc.flashWrite)
+		}
+	}
+}
+
+func (mbc *mbc6) MarshalBinary() ([]byte, error) {
+	state := mbc.marshalState(
+		boolToByte(mbc.ramEnabled),
+		mbc.ramBank[0], mbc.ramBank[1],
+		mbc.romBank[0], mbc.romBank[1],
+		boolToByte(mbc.flashMapped[0]), boolToByte(mbc.flashMapped[1]),
+		boolToByte(mbc.flashEnabled),
+		boolToByte(mbc.flashWrite),
+		byte(mbc.flash.state),
+	)
+	return append(state, mbc.flash.data...), nil
+}
+
+func (mbc *mbc6) UnmarshalBinary(data []byte) error {
+	if len(data) < mbc6FlashSize {
+		return fmt.Errorf("invalid MBC6 state, expected %d bytes of flash but got %d", mbc6FlashSize, len(data))
+	}
+	flashStart := len(data) - mbc6FlashSize
+
+	registers, err := mbc.unmarshalState(data[:flashStart], 10)
+	if err != nil {
+		return err
+	}
+
+	mbc.ramEnabled = registers[0] != 0
+	mbc.ramBank = [2]byte{registers[1], registers[2]}
+	mbc.romBank = [2]byte{registers[3], registers[4]}
+	mbc.flashMapped = [2]bool{registers[5] != 0, registers[6] != 0}
+	mbc.flashEnabled = registers[7] != 0
+	mbc.flashWrite = registers[8] != 0
+	mbc.flash.state = flashState(registers[9])
+	copy(mbc.flash.data, data[flashStart:])
+	return nil
+}
+
+// saveData returns the RAM followed by the flash, which is where the game
+// keeps most of what it saves
+func (mbc *mbc6) saveData() []byte {
+	return append(mbc.cartMemory.saveData(), mbc.flash.data...)
+}
+
+func (mbc *mbc6) loadSaveData(data []byte) error {
+	ramSize := min(len(data), len(mbc.ram))
+	if err := mbc.cartMemory.loadSaveData(data[:ramSize]); err != nil {
+		return err
+	}
+	copy(mbc.flash.data, data[ramSize:])
+	return nil
+}
+
+// flashState tracks progress through the JEDEC command sequences
+type flashState byte
+
+const (
+	flashRead         flashState = iota // Reading the array
+	flashUnlock1                        // Got 0xAA at 0x5555
+	flashUnlock2                        // Got 0x55 at 0x2AAA, expecting a command
+	flashProgram                        // Next write programs a byte
+	flashErase                          // Got the erase command, expecting a second unlock
+	flashEraseUnlock1                   // Erase sequence, got 0xAA
+	flashEraseUnlock2                   // Erase sequence, got 0x55, expecting sector or chip erase
+	flashID                             // Reading the manufacturer and device ID
+)
+
+type flash struct {
+	data  []byte
+	state flashState
+}
+
+func (f *flash) read(offset int) byte {
+	if f.state == flashID {
+		switch offset & 0x01 {
+		case 0:
+			return mbc6FlashMaker
+		default:
+			return mbc6FlashDevice
+		}
+	}
+	return f.data[offset]
+}
+
+func (f *flash) write(offset int, value byte, writeEnabled bool) {
+	if value == 0xF0 {
+		// Reset works from any state
+		f.state = flashRead
+		return
+	}
+
+	// Command addresses only decode the low 15 bits
+	cmdAddr := offset & 0x7FFF
+
+	switch f.state {
+	case flashRead, flashID:
+		if value == 0xAA && cmdAddr == 0x5555 {
+			f.state = flashUnlock1
+		}
+	case flashUnlock1:
+		f.state = flashRead
+		if value == 0x55 && cmdAddr == 0x2AAA {
+			f.state = flashUnlock2
+		}
+	case flashUnlock2:
+		f.state = flashRead
+		if cmdAddr != 0x5555 {
+			return
+		}
+		switch value {
+		case 0x80:
+			f.state = flashErase
+		case 0x90:
+			f.state = flashID
+		case 0xA0:
+			f.state = flashProgram
+		}
+	case flashProgram:
+		f.state = flashRead
+		if writeEnabled {
+			// Programming can only clear bits
+			f.data[offset] &= value
+		}
+	case flashErase:
+		f.state = flashRead
+		if value == 0xAA && cmdAddr == 0x5555 {
+			f.state = flashEraseUnlock1
+		}
+	case flashEraseUnlock1:
+		f.state = flashRead
+		if value == 0x55 && cmdAddr == 0x2AAA {
+			f.state = flashEraseUnlock2
+		}
+	case flashEraseUnlock2:
+		f.state = flashRead
+		if !writeEnabled {
+			return
+		}
+		switch {
+		case value == 0x30:
+			// Sector erase
+			start := offset / mbc6FlashSectorSize * mbc6FlashSectorSize
+			fill(f.data[start:start+mbc6FlashSectorSize], 0xFF)
+		case value == 0x10 && cmdAddr == 0x5555:
+			// Chip erase
+			fill(f.data, 0xFF)
+		}
+	}
+}
+
+func fill(data []byte, value byte) {
+	for i := range data {
+		data[i] = value
+	}
+}
@@ -0,0 +1,330 @@
+package memory
+
+import "math"
+
+// MBC7 accelerometer readings, 0x81D0 at rest with about 0x70 per g of tilt
+const (
+	mbc7AccelCenter = 0x81D0
+	mbc7AccelPerG   = 0x70
+	mbc7AccelErased = 0x8000
+)
+
+// mbc7EEPROMSize is the 93LC56 EEPROM, 128 16-bit words
+const mbc7EEPROMSize = 256
+
+// mbc7 is the MBC7 controller used by Kirby Tilt 'n' Tumble and Command
+// Master. Instead of RAM it has a two-axis accelerometer and a serial
+// EEPROM, both accessed through registers at 0xA000-0xAFFF.
+type mbc7 struct {
+	cartMemory           // ram holds the EEPROM contents
+	ramEnabled   [2]bool // Both 0x0000 and 0x4000 must be written to enable
+	romBank      byte
+	accelX       uint16 // Latched readings
+	accelY       uint16
+	accelErased  bool // Latch was erased, ready to take a reading
+	tiltX, tiltY float64
+	eeprom       eeprom
+}
+
+func newMBC7(rom []byte) *mbc7 {
+	mbc := &mbc7{
+		cartMemory: newCartMemory(rom, mbc7EEPROMSize),
+		romBank:    1,
+		accelX:     mbc7AccelErased,
+		accelY:     mbc7AccelErased,
+	}
+	// A blank EEPROM reads as all ones
+	fill(mbc.ram, 0xFF)
+	mbc.eeprom.data = mbc.ram
+	mbc.eeprom.reset()
+	return mbc
+}
+
+func (mbc *mbc7) ReadROM(addr uint16) byte {
+	if addr < 0x4000 {
+		return mbc.romByte(0, addr)
+	}
+	return mbc.romByte(int(mbc.romBank), addr)
+}
+
+func (mbc *mbc7) enabled() bool {
+	return mbc.ramEnabled[0] && mbc.ramEnabled[1]
+}
+
+func (mbc *mbc7) ReadRAM(addr uint16) byte {
+	if !mbc.enabled() || addr >= 0xB000 {
+		return 0xFF
+	}
+
+	// Registers are selected by bits 4-7 and mirrored across the rest
+	switch (addr >> 4) & 0x0F {
+	case 0x2:
+		return byte(mbc.accelX)
+	case 0x3:
+		return byte(mbc.accelX >> 8)
+	case 0x4:
+		return byte(mbc.accelY)
+	case 0x5:
+		return byte(mbc.accelY >> 8)
+	case 0x6:
+		return 0x00
+	case 0x8:
+		return mbc.eeprom.read()
+	}
+	return 0xFF
+}
+
+func (mbc *mbc7) WriteRAM(addr uint16, value byte) {
+	if !mbc.enabled() || addr >= 0xB000 {
+		return
+	}
+
+	switch (addr >> 4) & 0x0F {
+	case 0x0:
+		// Erase the latched reading
+		if value == 0x55 {
+			mbc.accelX = mbc7AccelErased
+			mbc.accelY = mbc7AccelErased
+			mbc.accelErased = true
+		}
+	case 0x1:
+		// Latch a new reading, only after an erase
+		if value == 0xAA && mbc.accelErased {
+			mbc.accelX = accelReading(mbc.tiltX)
+			mbc.accelY = accelReading(mbc.tiltY)
+			mbc.accelErased = false
+		}
+	case 0x8:
+		mbc.eeprom.write(value)
+	}
+}
+
+// accelReading converts a tilt in g to the sensor's output
+func accelReading(g float64) uint16 {
+	reading := mbc7AccelCenter + math.Round(g*mbc7AccelPerG)
+	return uint16(max(0, min(0xFFFF, reading)))
+}
+
+func (mbc *mbc7) WriteControl(addr uint16, value byte) {
+	if addr < 0x2000 {
+		// First RAM enable
+		mbc.ramEnabled[0] = value == 0x0A
+		if !mbc.ramEnabled[0] {
+			mbc.ramEnabled[1] = false
+		}
+	} else if addr < 0x4000 {
+		// ROM bank number
+		mbc.romBank = value & 0x7F
+	} else if addr < 0x6000 {
+		// Second RAM enable, only takes effect once the first is set
+		mbc.ramEnabled[1] = mbc.ramEnabled[0] && value == 0x40
+	}
+}
+
+func (mbc *mbc7) setAccelerometer(x, y float64) {
+	mbc.tiltX = x
+	mbc.tiltY = y
+}
+
+func (mbc *mbc7) MarshalBinary() ([]byte, error) {
+	return mbc.marshalState(
+		boolToByte(mbc.ramEnabled[0]),
+		boolToByte(mbc.ramEnabled[1]),
+		mbc.romBank,
+		byte(mbc.accelX), byte(mbc.accelX>>8),
+		byte(mbc.accelY), byte(mbc.accelY>>8),
+		boolToByte(mbc.accelErased),
+	), nil
+}
+
+func (mbc *mbc7) UnmarshalBinary(data []byte) error {
+	registers, err := mbc.unmarshalState(data, 8)
+	if err != nil {
+		return err
+	}
+
+	mbc.ramEnabled = [2]bool{registers[0] != 0, registers[1] != 0}
+	mbc.romBank = registers[2]
+	mbc.accelX = uint16(registers[3]) | uint16(registers[4])<<8
+	mbc.accelY = uint16(registers[5]) | uint16(registers[6])<<8
+	mbc.accelErased = registers[7] != 0
+	// A transfer in progress is abandoned, as if chip select dropped
+	mbc.eeprom.reset()
+	return nil
+}
+
+// EEPROM register bits at 0xA080
+const (
+	eepromDO  = 0x01 // Data out
+	eepromDI  = 0x02 // Data in
+	eepromCLK = 0x40 // Clock
+	eepromCS  = 0x80 // Chip select
+)
+
+// eepromState tracks progress through a serial command
+type eepromState byte
+
+const (
+	eepromIdle    eepromState = iota // Waiting for a start bit
+	eepromCommand                    // Shifting in the opcode and address
+	eepromReading                    // Shifting out words
+	eepromWriting                    // Shifting in a word to write
+	eepromDone                       // Command finished, waiting for chip select to drop
+)
+
+// eeprom is a 93LC56 serial EEPROM in 16-bit mode. Commands are a start
+// bit, a 2-bit opcode and an 8-bit address, clocked in on rising edges of
+// CLK while CS is high.
+type eeprom struct {
+	data     []byte // Words stored little endian
+	state    eepromState
+	cs, clk  bool
+	di, do   bool
+	writable bool // Set by EWEN, cleared by EWDS
+	shift    uint16
+	bits     int
+	address  byte
+	writeAll bool // Writing shifts into every word, for WRAL
+}
+
+func (e *eeprom) reset() {
+	e.state = eepromIdle
+	e.do = true // Ready
+}
+
+func (e *eeprom) read() byte {
+	value := byte(0xFF) &^ (eepromDO | eepromDI | eepromCLK | eepromCS)
+	if e.cs {
+		value |= eepromCS
+	}
+	if e.clk {
+		value |= eepromCLK
+	}
+	if e.di {
+		value |= eepromDI
+	}
+	if e.do {
+		value |= eepromDO
+	}
+	return value
+}
+
+func (e *eeprom) write(value byte) {
+	cs := value&eepromCS != 0
+	clk := value&eepromCLK != 0
+	e.di = value&eepromDI != 0
+
+	if !cs {
+		if e.cs {
+			e.reset()
+		}
+		e.cs, e.clk = false, clk
+		return
+	}
+
+	rising := clk && !e.clk
+	e.cs, e.clk = true, clk
+	if rising {
+		e.clock()
+	}
+}
+
+// clock handles one rising edge of CLK
+func (e *eeprom) clock() {
+	switch e.state {
+	case eepromIdle:
+		if e.di {
+			e.state = eepromCommand
+			e.shift = 0
+			e.bits = 0
+		}
+	case eepromCommand:
+		e.shiftIn()
+		if e.bits == 10 {
+			e.command(byte(e.shift>>8), byte(e.shift))
+		}
+	case eepromReading:
+		e.do = e.shift&0x8000 != 0
+		e.shift <<= 1
+		e.bits++
+		if e.bits == 16 {
+			// Reads continue into the next word
+			e.address = (e.address + 1) & 0x7F
+			e.shift = e.word(e.address)
+			e.bits = 0
+		}
+	case eepromWriting:
+		e.shiftIn()
+		if e.bits == 16 {
+			if e.writeAll {
+				for address := byte(0); address < 0x80; address++ {
+					e.setWord(address, e.shift)
+				}
+			} else {
+				e.setWord(e.address, e.shift)
+			}
+			e.do = true
+			e.state = eepromDone
+		}
+	}
+}
+
+func (e *eeprom) shiftIn() {
+	e.shift <<= 1
+	if e.di {
+		e.shift |= 1
+	}
+	e.bits++
+}
+
+func (e *eeprom) command(opcode byte, address byte) {
+	e.address = address & 0x7F
+	e.state = eepromDone
+	e.bits = 0
+	e.shift = 0
+
+	switch opcode {
+	case 0x0:
+		switch address >> 6 {
+		case 0x0:
+			// EWDS
+			e.writable = false
+		case 0x1:
+			// WRAL
+			e.state = eepromWriting
+			e.writeAll = true
+		case 0x2:
+			// ERAL
+			for address := byte(0); address < 0x80; address++ {
+				e.setWord(address, 0xFFFF)
+			}
+		case 0x3:
+			// EWEN
+			e.writable = true
+		}
+	case 0x1:
+		// WRITE
+		e.state = eepromWriting
+		e.writeAll = false
+	case 0x2:
+		// READ, a dummy zero bit comes out first
+		e.state = eepromReading
+		e.shift = e.word(e.address)
+		e.do = false
+	case 0x3:
+		// ERASE
+		e.setWord(e.address, 0xFFFF)
+	}
+}
+
+func (e *eeprom) word(address byte) uint16 {
+	return uint16(e.data[address*2]) | uint16(e.data[address*2+1])<<8
+}
+
+func (e *eeprom) setWord(address byte, value uint16) {
+	if !e.writable {
+		return
+	}
+	e.data[address*2] = byte(value)
+	e.data[address*2+1] = byte(value >> 8)
+}
@@ -0,0 +1,164 @@
+package memory
+
+// mmm01 is the MMM01 multicart controller. It starts unmapped, showing the
+// menu in the last 32KB of ROM, which configures which slice of the ROM
+// and RAM the chosen game sees and then locks that in. Once mapped it
+// behaves like an MBC1 within the slice.
+type mmm01 struct {
+	cartMemory
+	mapped     bool
+	ramEnabled bool
+	romBankLow byte // Bits 0-4, 0x2000-0x3FFF
+	romBankMid byte // Bits 5-6, 0x2000-0x3FFF bits 5-6
+	romBankHi  byte // Bits 7-8, 0x4000-0x5FFF bits 4-5
+	romMask    byte // ROM bank bits 1-4 that are fixed once mapped
+	ramBankLow byte // Bits 0-1, 0x4000-0x5FFF bits 0-1
+	ramBankHi  byte // Bits 2-3, 0x4000-0x5FFF bits 2-3
+	ramMask    byte // RAM bank bits 0-1 that are fixed once mapped
+	mode       byte // MBC1 banking mode
+	modeLocked bool // Banking mode can't be changed once mapped
+}
+
+func newMMM01(banks cartMemory) *mmm01 {
+	return &mmm01{cartMemory: banks}
+}
+
+// isMMM01 reports whether a cartridge type is one of the MMM01's
+func isMMM01(cartridgeType byte) bool {
+	return cartridgeType >= 0x0B && cartridgeType <= 0x0D
+}
+
+// hardwareHeader returns the header that describes the cartridge hardware.
+// For an MMM01 multicart that is the menu's, in the last 32KB of ROM, as
+// bank 0 usually holds the first game with a header of its own. The menu
+// header only counts when it is a complete one, with the logo and a
+// correct checksum, since in other ROMs the same bytes are code or data. A
+// type from the ROM database overrides both.
+func (cart *Cartridge) hardwareHeader() Header {
+	if cart.entry.Overrides.CartridgeType == nil && len(cart.rom) >= 0x10000 {
+		slice := cart.rom[len(cart.rom)-0x8000:]
+		if menu := parseHeader(slice); isMMM01(menu.CartridgeType) && isValidHeader(slice) {
+			return menu
+		}
+	}
+	return cart.header
+}
+
+// romBank returns the bank mapped at 0x4000-0x7FFF, or at 0x0000-0x3FFF
+// when zero is true
+func (mbc *mmm01) romBank(zero bool) int {
+	low := mbc.romBankLow
+	if zero {
+		// The bank 0 area clears the bits the game can change
+		low &= mbc.romMask << 1
+	} else if low&^(mbc.romMask<<1) == 0 {
+		// MBC1's bank 0 quirk, within the game's slice
+		low |= 0x01
+	}
+	return int(mbc.romBankHi)<<7 | int(mbc.romBankMid)<<5 | int(low)
+}
+
+func (mbc *mmm01) ReadROM(addr uint16) byte {
+	if !mbc.mapped {
+		// The menu lives in the last 32KB
+		return mbc.romByte(max(0, mbc.romBanks-2)+int(addr>>14), addr)
+	}
+	return mbc.romByte(mbc.romBank(addr < 0x4000), addr)
+}
+
+func (mbc *mmm01) ramBank() int {
+	low := mbc.ramBankLow
+	if mbc.mode == 0 {
+		low &= mbc.ramMask
+	}
+	return int(mbc.ramBankHi)<<2 | int(low)
+}
+
+func (mbc *mmm01) ReadRAM(addr uint16) byte {
+	if !mbc.ramEnabled {
+		return 0xFF
+	}
+	return mbc.ramByte(mbc.ramBank(), addr)
+}
+
+func (mbc *mmm01) WriteRAM(addr uint16, value byte) {
+	if mbc.ramEnabled {
+		mbc.setRAMByte(mbc.ramBank(), addr, value)
+	}
+}
+
+// update replaces the bits of old that mask allows to change
+func update(old, value, mask byte) byte {
+	return old&^mask | value&mask
+}
+
+func (mbc *mmm01) WriteControl(addr uint16, value byte) {
+	if addr < 0x2000 {
+		mbc.ramEnabled = value&0x0F == 0x0A
+		if !mbc.mapped {
+			mbc.ramMask = (value >> 4) & 0x03
+			mbc.mapped = value&0x40 != 0
+		}
+	} else if addr < 0x4000 {
+		writable := byte(0x1F)
+		if mbc.mapped {
+			writable &^= mbc.romMask << 1
+		} else {
+			mbc.romBankMid = (value >> 5) & 0x03
+		}
+		mbc.romBankLow = update(mbc.romBankLow, value, writable)
+	} else if addr < 0x6000 {
+		writable := byte(0x03)
+		if mbc.mapped {
+			writable &^= mbc.ramMask
+		} else {
+			mbc.ramBankHi = (value >> 2) & 0x03
+			mbc.romBankHi = (value >> 4) & 0x03
+			mbc.modeLocked = value&0x40 != 0
+		}
+		mbc.ramBankLow = update(mbc.ramBankLow, value, writable)
+	} else {
+		if !mbc.mapped || !mbc.modeLocked {
+			mbc.mode = value & 0x01
+		}
+		if !mbc.mapped {
+			mbc.romMask = (value >> 2) & 0x0F
+		}
+	}
+}
+
+func (mbc *mmm01) MarshalBinary() ([]byte, error) {
+	return mbc.marshalState(
+		boolToByte(mbc.mapped),
+		boolToByte(mbc.ramEnabled),
+		mbc.romBankLow,
+		mbc.romBankMid,
+		mbc.romBankHi,
+		mbc.romMask,
+		mbc.ramBankLow,
+		mbc.ramBankHi,
+		mbc.ramMask,
+		mbc.mode,
+		boolToByte(mbc.modeLocked),
+	), nil
+}
+
+func (mbc *mmm01) UnmarshalBinary(data []byte) error {
+	registers, err := mbc.unmarshalState(data, 11)
+	if err != nil {
+		return err
+	}
+
+	mbc.mapped = registers[0] != 0
+	mbc.ramEnabled = registers[1] != 0
+	mbc.romBankLow = registers[2]
+	mbc.romBankMid = registers[3]
+	mbc.romBankHi = registers[4]
+	mbc.romMask = registers[5]
+	mbc.ramBankLow = registers[6]
+	mbc.ramBankHi = registers[7]
+	mbc.ramMask = registers[8]
+	mbc.mode = registers[9]
+	mbc.modeLocked = registers[10] != 0
+	return nil
+}
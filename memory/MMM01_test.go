@@ -0,0 +1,89 @@
+package memory
+
+import "testing"
+
+func TestMMM01DetectedFromMenuHeader(t *testing.T) {
+	rom := make([]byte, 0x20000)
+	// The first game's header in bank 0 looks like an MBC1 cartridge
+	rom[addrCartridgeType] = 0x01
+	rom[addrRAMSize] = 0x00
+	// The menu's header in the last 32KB declares the MMM01
+	menu := rom[len(rom)-0x8000:]
+	copy(menu[addrLogo:], nintendoLogo)
+	menu[addrCartridgeType] = 0x0D
+	menu[addrRAMSize] = 0x02
+	menu[addrHeaderChecksum] = computeHeaderChecksum(menu)
+	menu[0x0000] = 0x4D
+
+	cart, err := NewCartridge(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem, err := NewMemory(cart)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mbc, ok := mem.mbc.(*mmm01)
+	if !ok {
+		t.Fatalf("controller is %T, want *mmm01", mem.mbc)
+	}
+	if len(mbc.ram) != 8*1024 {
+		t.Errorf("RAM is %d bytes, want the menu header's 8KB", len(mbc.ram))
+	}
+	if !mem.HasBattery() {
+		t.Error("HasBattery() = false for MMM01+RAM+BATTERY")
+	}
+	if got := mem.Read(0x0000); got != 0x4D {
+		t.Errorf("0x0000 reads 0x%02X, want the menu's 0x4D", got)
+	}
+}
+
+func TestMBC1NotMistakenForMMM01(t *testing.T) {
+	tests := []struct {
+		name string
+		// stray is written where an MMM01 menu would keep its type
+		stray byte
+		// logo also puts the Nintendo logo there, without a checksum
+		logo bool
+	}{
+		{"no menu", 0x00, false},
+		{"stray 0x0B", 0x0B, false},
+		{"stray 0x0C", 0x0C, false},
+		{"stray 0x0D", 0x0D, false},
+		{"logo without checksum", 0x0D, true},
+	}
+
+	for _, test := range tests {
+		rom := make([]byte, 0x20000)
+		rom[addrCartridgeType] = 0x03 // MBC1+RAM+BATTERY
+		rom[addrRAMSize] = 0x02
+		slice := rom[len(rom)-0x8000:]
+		slice[addrCartridgeType] = test.stray
+		if test.logo {
+			copy(slice[addrLogo:], nintendoLogo)
+			slice[addrHeaderChecksum] = computeHeaderChecksum(slice) + 1
+		}
+
+		cart, err := NewCartridge(rom)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mem, err := NewMemory(cart)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mbc, ok := mem.mbc.(*mbc1)
+		if !ok {
+			t.Errorf("%s: controller is %T, want *mbc1", test.name, mem.mbc)
+			continue
+		}
+		if len(mbc.ram) != 8*1024 {
+			t.Errorf("%s: RAM is %d bytes, want 8KB", test.name, len(mbc.ram))
+		}
+		if !mem.HasBattery() {
+			t.Errorf("%s: HasBattery() = false for MBC1+RAM+BATTERY", test.name)
+		}
+	}
+}
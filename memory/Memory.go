@@ -1,78 +1,123 @@
 package memory
 
-import (
-	"fmt"
-)
-
 type Memory struct {
-	cartridge   *Cartridge
-	mbc         MBC
-	vram        []byte
-	externalram []byte
-	wram        []byte
-	oam         []byte
-	io          []byte
-	hram        []byte
-	ie          byte
+	cartridge *Cartridge
+	mbc       MBC
+	vram      []byte
+	wram      []byte
+	oam       []byte
+	io        []byte
+	hram      []byte
+	ie        byte
+	model     Model
+	cgb       bool
+
+	bootROM       []byte
+	bootROMMapped bool
+
+	speedSwitchArmed bool // KEY1 prepare bit
+	doubleSpeed      bool
+
+	ports [0x80]ioPort // Devices wired to the I/O registers
+
+	dma  oamDMA
+	hdma vramDMA
 }
 
-type MBC struct {
-	Type       byte
-	ROMBank    int
-	RAMBank    int
-	RAMEnabled bool
-	Mode       bool
+// NewMemory initializes the memory with a loaded cartridge, in the state
+// the original Game Boy's boot ROM leaves it
+func NewMemory(cart *Cartridge) (*Memory, error) {
+	return NewMemoryWithModel(cart, ModelDMG)
 }
 
-// NewMemory initializes the memory with a loaded cartridge
-func NewMemory(cart *Cartridge) *Memory {
+// NewMemoryWithModel initializes the memory with a loaded cartridge, in the
+// state the given model's boot ROM leaves it
+func NewMemoryWithModel(cart *Cartridge, model Model) (*Memory, error) {
+	mbc, err := newMBC(cart)
+	if err != nil {
+		return nil, err
+	}
+
 	mem := &Memory{
-		cartridge:   cart,
-		mbc:         MBC{Type: cart.mbcType, ROMBank: 1}, // Default to ROM bank 1
-		vram:        make([]byte, 8*1024),                // 8KB
-		externalram: make([]byte, cart.ramSize),
-		wram:        make([]byte, 8*1024), // 8KB
-		oam:         make([]byte, 160),    // 160 bytes
-		io:          make([]byte, 128),    // 128 bytes
-		hram:        make([]byte, 127),    // 127 bytes
-		ie:          0,
+		cartridge: cart,
+		mbc:       mbc,
+		vram:      make([]byte, 16*1024), // 2 8KB banks, the second for CGB
+		wram:      make([]byte, 32*1024), // 8 4KB banks, 2-7 for CGB
+		oam:       make([]byte, 160),     // 160 bytes
+		io:        make([]byte, 128),     // 128 bytes
+		hram:      make([]byte, 127),     // 127 bytes
+		ie:        0,
+		model:     model,
+		// CGB features are only unlocked for cartridges that support them
+		cgb: model.IsCGB() && cart.header.CGB != CGBNone,
+	}
+
+	mem.resetIO(model)
+	mem.registerDevices()
+
+	return mem, nil
+}
+
+// Model returns the hardware model being emulated
+func (mem *Memory) Model() Model {
+	return mem.model
+}
+
+// Tick advances the hardware that runs alongside the CPU by the number of
+// machine cycles the CPU just spent
+func (mem *Memory) Tick(cycles int) {
+	mem.tickOAMDMA(cycles)
+
+	if ticker, ok := mem.mbc.(cycleTicker); ok {
+		// Cartridge hardware runs off its own crystal, unaffected by
+		// the CGB double speed mode
+		rtcTicks := cycles * 2
+		if mem.DoubleSpeed() {
+			rtcTicks = cycles
+		}
+		ticker.tick(rtcTicks)
+	}
+}
+
+// SetRumbleHandler registers a function called whenever a rumble
+// cartridge switches its motor on or off, so a frontend can vibrate a
+// controller. It is never called for other cartridges.
+func (mem *Memory) SetRumbleHandler(handler func(on bool)) {
+	if cart, ok := mem.mbc.(rumbleCartridge); ok {
+		cart.setRumbleHandler(handler)
+	}
+}
+
+// SetRTCClock selects what drives the cartridge's real-time clock, if it
+// has one. The default is RTCWallClock.
+func (mem *Memory) SetRTCClock(clock RTCClock) {
+	if cart, ok := mem.mbc.(rtcCartridge); ok {
+		cart.setRTCClock(clock)
 	}
+}
+
+// SetAccelerometer sets the tilt a motion sensor cartridge reads, in g
+// along each axis, with 0 for a level Game Boy. It is ignored for other
+// cartridges.
+func (mem *Memory) SetAccelerometer(x, y float64) {
+	if cart, ok := mem.mbc.(accelerometerCartridge); ok {
+		cart.setAccelerometer(x, y)
+	}
+}
 
-	// Initialize IO registers with default values
-	mem.io[0x05] = 0x00 // TIMA
-	mem.io[0x06] = 0x00 // TMA
-	mem.io[0x07] = 0x00 // TAC
-	mem.io[0x10] = 0x80 // NR10
-	mem.io[0x11] = 0xBF // NR11
-	mem.io[0x12] = 0xF3 // NR12
-	mem.io[0x14] = 0xBF // NR14
-	mem.io[0x16] = 0x3F // NR21
-	mem.io[0x17] = 0x00 // NR22
-	mem.io[0x19] = 0xBF // NR24
-	mem.io[0x1A] = 0x7F // NR30
-	mem.io[0x1B] = 0xFF // NR31
-	mem.io[0x1C] = 0x9F // NR32
-	mem.io[0x1E] = 0xBF // NR34
-	mem.io[0x20] = 0xFF // NR41
-	mem.io[0x21] = 0x00 // NR42
-	mem.io[0x22] = 0x00 // NR43
-	mem.io[0x23] = 0xBF // NR44
-	mem.io[0x24] = 0x77 // NR50
-	mem.io[0x25] = 0xF3 // NR51
-	mem.io[0x26] = 0xF1 // NR52
-	mem.io[0x40] = 0x91 // LCDC
-	mem.io[0x41] = 0x85 // STAT
-	mem.io[0x42] = 0x00 // SCY
-	mem.io[0x43] = 0x00 // SCX
-	mem.io[0x45] = 0x00 // LYC
-	mem.io[0x47] = 0xFC // BGP
-	mem.io[0x48] = 0xFF // OBP0
-	mem.io[0x49] = 0xFF // OBP1
-	mem.io[0x4A] = 0x00 // WY
-	mem.io[0x4B] = 0x00 // WX
-	mem.io[0xFF] = 0x00 // IE
-
-	return mem
+// SetInfrared connects the cartridge's infrared port, if it has one
+func (mem *Memory) SetInfrared(ir Infrared) {
+	if cart, ok := mem.mbc.(infraredCartridge); ok {
+		cart.setInfrared(ir)
+	}
+}
+
+// SetCameraSource selects where the Pocket Camera's sensor takes its
+// images from. Without one it sees black.
+func (mem *Memory) SetCameraSource(source CameraSource) {
+	if cart, ok := mem.mbc.(cameraCartridge); ok {
+		cart.setCameraSource(source)
+	}
 }
 
 // 0x0000 - 0x3FFF: ROM Bank 0
@@ -87,23 +132,98 @@ func NewMemory(cart *Cartridge) *Memory {
 // 0xFF80 - 0xFFFE: High RAM
 // 0xFFFF - 0xFFFF: Interrupt Enable Register
 
+// Read is a read by the CPU. While an OAM DMA transfer runs, the CPU sees
+// the byte being transferred anywhere outside HRAM and the I/O registers.
 func (mem *Memory) Read(addr uint16) byte {
-	if addr < 0x4000 {
-		// ROM Bank 0
-		return mem.cartridge.rom[addr]
-	} else if addr < 0x8000 {
-		// Switchable ROM bank
-		offset := uint32(mem.mbc.ROMBank) * 0x4000
-		return mem.cartridge.rom[offset+uint32(addr-0x4000)]
+	if mem.dmaBlocks(addr) {
+		return mem.dma.value
+	}
+	return mem.read(addr)
+}
+
+// Write is a write by the CPU. While an OAM DMA transfer runs, writes
+// outside HRAM and the I/O registers are lost.
+func (mem *Memory) Write(addr uint16, value byte) {
+	if mem.dmaBlocks(addr) {
+		return
+	}
+	mem.write(addr, value)
+}
+
+// read returns the byte at addr without regard for who else is using the
+// bus, for the DMA and for the CPU when the bus is free
+func (mem *Memory) read(addr uint16) byte {
+	if mem.bootROMMapped && mem.inBootROM(addr) {
+		// Boot ROM overlay
+		return mem.bootROM[addr]
+	}
+
+	if addr < 0x8000 {
+		// ROM, banked by the cartridge's controller
+		return mem.mbc.ReadROM(addr)
 	} else if addr < 0xA000 {
 		// VRAM
-		return mem.vram[addr-0x8000]
+		return mem.vram[mem.vramOffset(addr)]
+	} else if addr < 0xC000 {
+		// External RAM
+		return mem.mbc.ReadRAM(addr)
+	} else if addr < 0xE000 {
+		// WRAM
+		return mem.wram[mem.wramOffset(addr)]
+	} else if addr < 0xFE00 {
+		// Echo RAM
+		return mem.wram[mem.wramOffset(addr-0x2000)]
+	} else if addr < 0xFEA0 {
+		// OAM
+		return mem.oam[addr-0xFE00]
+	} else if addr < 0xFF00 {
+		// Not usable
+		if mem.model.IsCGB() {
+			// CGB repeats the high nibble of the low address byte
+			return byte(addr&0xF0) | byte(addr&0xF0)>>4
+		}
+		return 0x00
+	} else if addr < 0xFF80 {
+		// I/O Registers
+		return mem.readIO(addr)
+	} else if addr < 0xFFFF {
+		// HRAM
+		return mem.hram[addr-0xFF80]
 	}
 
-	fmt.Println("Unimplemented read")
-	return 0
+	// Interrupt Enable
+	return mem.ie
 }
 
-func (mem *Memory) Write(addr uint16, value byte) {
-	fmt.Println("Unimplemented write")
+func (mem *Memory) write(addr uint16, value byte) {
+	if addr < 0x8000 {
+		// ROM is read only, writes control the memory bank controller
+		mem.mbc.WriteControl(addr, value)
+	} else if addr < 0xA000 {
+		// VRAM
+		mem.vram[mem.vramOffset(addr)] = value
+	} else if addr < 0xC000 {
+		// External RAM
+		mem.mbc.WriteRAM(addr, value)
+	} else if addr < 0xE000 {
+		// WRAM
+		mem.wram[mem.wramOffset(addr)] = value
+	} else if addr < 0xFE00 {
+		// Echo RAM
+		mem.wram[mem.wramOffset(addr-0x2000)] = value
+	} else if addr < 0xFEA0 {
+		// OAM
+		mem.oam[addr-0xFE00] = value
+	} else if addr < 0xFF00 {
+		// Not usable, writes are ignored
+	} else if addr < 0xFF80 {
+		// I/O Registers
+		mem.writeIO(addr, value)
+	} else if addr < 0xFFFF {
+		// HRAM
+		mem.hram[addr-0xFF80] = value
+	} else {
+		// Interrupt Enable
+		mem.ie = value
+	}
 }
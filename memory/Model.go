@@ -0,0 +1,124 @@
+package memory
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Model is the Game Boy hardware revision being emulated. It decides the
+// state the boot ROM leaves the CPU and I/O registers in, and whether the
+// CGB-only hardware is present.
+type Model int
+
+const (
+	ModelDMG0 Model = iota // Early original Game Boy
+	ModelDMG               // Original Game Boy
+	ModelMGB               // Game Boy Pocket
+	ModelSGB               // Super Game Boy
+	ModelSGB2              // Super Game Boy 2
+	ModelCGB               // Game Boy Color
+	ModelAGB               // Game Boy Advance in Game Boy Color mode
+)
+
+var modelNames = [...]string{"DMG0", "DMG", "MGB", "SGB", "SGB2", "CGB", "AGB"}
+
+func (m Model) String() string {
+	if int(m) < len(modelNames) {
+		return modelNames[m]
+	}
+	return fmt.Sprintf("Model(%d)", int(m))
+}
+
+// IsCGB reports whether the model has the Game Boy Color hardware
+func (m Model) IsCGB() bool {
+	return m == ModelCGB || m == ModelAGB
+}
+
+// IsSGB reports whether the model is a Super Game Boy
+func (m Model) IsSGB() bool {
+	return m == ModelSGB || m == ModelSGB2
+}
+
+// ParseModel parses a model name such as "DMG" or "cgb"
+func ParseModel(name string) (Model, error) {
+	for i, n := range modelNames {
+		if strings.EqualFold(n, name) {
+			return Model(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown model %q", name)
+}
+
+// resetIO sets every I/O register to the value the model's boot ROM leaves
+// it at. Registers the boot ROM leaves in an unpredictable state get the
+// value most commonly observed on hardware.
+func (mem *Memory) resetIO(model Model) {
+	for i := range mem.io {
+		mem.io[i] = 0xFF // Unmapped registers read as 0xFF
+	}
+
+	set := func(addr uint16, value byte) {
+		mem.io[addr-0xFF00] = value
+	}
+
+	set(0xFF00, 0xCF) // P1
+	set(0xFF01, 0x00) // SB
+	set(0xFF02, 0x7E) // SC
+	set(0xFF04, 0xAB) // DIV
+	set(0xFF05, 0x00) // TIMA
+	set(0xFF06, 0x00) // TMA
+	set(0xFF07, 0xF8) // TAC
+	set(0xFF0F, 0xE1) // IF
+	set(0xFF10, 0x80) // NR10
+	set(0xFF11, 0xBF) // NR11
+	set(0xFF12, 0xF3) // NR12
+	set(0xFF13, 0xFF) // NR13
+	set(0xFF14, 0xBF) // NR14
+	set(0xFF16, 0x3F) // NR21
+	set(0xFF17, 0x00) // NR22
+	set(0xFF18, 0xFF) // NR23
+	set(0xFF19, 0xBF) // NR24
+	set(0xFF1A, 0x7F) // NR30
+	set(0xFF1B, 0xFF) // NR31
+	set(0xFF1C, 0x9F) // NR32
+	set(0xFF1D, 0xFF) // NR33
+	set(0xFF1E, 0xBF) // NR34
+	set(0xFF20, 0xFF) // NR41
+	set(0xFF21, 0x00) // NR42
+	set(0xFF22, 0x00) // NR43
+	set(0xFF23, 0xBF) // NR44
+	set(0xFF24, 0x77) // NR50
+	set(0xFF25, 0xF3) // NR51
+	set(0xFF26, 0xF1) // NR52
+	set(0xFF40, 0x91) // LCDC
+	set(0xFF41, 0x85) // STAT
+	set(0xFF42, 0x00) // SCY
+	set(0xFF43, 0x00) // SCX
+	set(0xFF44, 0x00) // LY
+	set(0xFF45, 0x00) // LYC
+	set(0xFF46, 0xFF) // DMA
+	set(0xFF47, 0xFC) // BGP
+	set(0xFF48, 0xFF) // OBP0
+	set(0xFF49, 0xFF) // OBP1
+	set(0xFF4A, 0x00) // WY
+	set(0xFF4B, 0x00) // WX
+
+	switch {
+	case model == ModelDMG0:
+		set(0xFF04, 0x18) // DIV
+		set(0xFF41, 0x81) // STAT
+		set(0xFF44, 0x91) // LY
+	case model.IsSGB():
+		set(0xFF04, 0x00) // DIV
+		set(0xFF26, 0xF0) // NR52
+	case model.IsCGB():
+		set(0xFF02, 0x7F) // SC
+		set(0xFF04, 0x00) // DIV
+		set(0xFF46, 0x00) // DMA
+		set(0xFF4F, 0x00) // VBK, unused bits are added on read
+		set(0xFF56, 0x3E) // RP
+		set(0xFF68, 0xC0) // BCPS
+		set(0xFF6A, 0xC1) // OCPS
+		set(0xFF70, 0x00) // SVBK, unused bits are added on read
+	}
+}
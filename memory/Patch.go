@@ -0,0 +1,339 @@
+package memory
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"math"
+	"os"
+)
+
+// patchExtensions are the patch files looked for next to a ROM, in order
+// of preference
+var patchExtensions = []string{".bps", ".ups", ".ips"}
+
+// findPatch returns the patch with the same name as the ROM at romPath, or
+// an empty string when there isn't one
+func findPatch(romPath string) string {
+	base := trimROMExt(romPath)
+	for _, ext := range patchExtensions {
+		path := base + ext
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// ApplyPatch returns a copy of rom with an IPS, UPS or BPS patch applied.
+// The format is recognised from the patch's signature. UPS and BPS patches
+// carry checksums of the ROM they were made for and the result, which are
+// verified.
+func ApplyPatch(rom []byte, patch []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(patch, []byte("PATCH")):
+		return applyIPS(rom, patch)
+	case bytes.HasPrefix(patch, []byte("UPS1")):
+		return applyUPS(rom, patch)
+	case bytes.HasPrefix(patch, []byte("BPS1")):
+		return applyBPS(rom, patch)
+	}
+	return nil, errors.New("unknown patch format, expected IPS, UPS or BPS")
+}
+
+// maxPatchSize limits the sizes a UPS or BPS patch declares to the largest
+// Game Boy ROM
+const maxPatchSize = maxROMSize
+
+var (
+	errPatchTruncated = errors.New("patch is truncated")
+	errPatchNumber    = errors.New("patch has a number too large to decode")
+	errPatchTooLarge  = fmt.Errorf("patch declares a size over %d bytes", maxPatchSize)
+)
+
+// applyIPS applies an IPS patch: records of a 24-bit offset and 16-bit
+// length followed by the data, or by a 16-bit run length and a byte to
+// repeat when the length is 0. "EOF" ends the records and may be followed
+// by a 24-bit size to truncate the ROM to.
+func applyIPS(rom []byte, patch []byte) ([]byte, error) {
+	target := append([]byte(nil), rom...)
+	p := patch[5:]
+
+	for {
+		if len(p) < 3 {
+			return nil, errPatchTruncated
+		}
+		if string(p[:3]) == "EOF" {
+			p = p[3:]
+			break
+		}
+		if len(p) < 5 {
+			return nil, errPatchTruncated
+		}
+
+		offset := int(p[0])<<16 | int(p[1])<<8 | int(p[2])
+		size := int(p[3])<<8 | int(p[4])
+		p = p[5:]
+
+		var data []byte
+		if size == 0 {
+			// Run length encoded
+			if len(p) < 3 {
+				return nil, errPatchTruncated
+			}
+			data = bytes.Repeat([]byte{p[2]}, int(p[0])<<8|int(p[1]))
+			p = p[3:]
+		} else {
+			if len(p) < size {
+				return nil, errPatchTruncated
+			}
+			data = p[:size]
+			p = p[size:]
+		}
+
+		if end := offset + len(data); end > len(target) {
+			target = append(target, make([]byte, end-len(target))...)
+		}
+		copy(target[offset:], data)
+	}
+
+	if len(p) >= 3 {
+		size := int(p[0])<<16 | int(p[1])<<8 | int(p[2])
+		if size < len(target) {
+			target = target[:size]
+		}
+	}
+	return target, nil
+}
+
+// patchReader decodes the variable length integers UPS and BPS use
+type patchReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *patchReader) byte() byte {
+	if r.pos >= len(r.data) {
+		r.err = errPatchTruncated
+		return 0
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+// number reads a little endian base-128 number, where each continuation
+// also adds one so every value has a single encoding. A number too large
+// for an int is an error rather than wrapping round to a negative one.
+func (r *patchReader) number() int {
+	value, shift := uint64(0), uint(0)
+	for r.err == nil {
+		b := r.byte()
+		digit := uint64(b & 0x7F)
+		if digit > (math.MaxInt64-value)>>shift {
+			r.err = errPatchNumber
+			return 0
+		}
+		value += digit << shift
+		if b&0x80 != 0 {
+			break
+		}
+
+		shift += 7
+		if shift >= 63 || value > math.MaxInt64-1<<shift {
+			r.err = errPatchNumber
+			return 0
+		}
+		value += 1 << shift
+	}
+	return int(value)
+}
+
+// size reads a number that is a size or offset within a ROM, so can't be
+// larger than the largest one
+func (r *patchReader) size() int {
+	size := r.number()
+	if r.err == nil && size > maxPatchSize {
+		r.err = errPatchTooLarge
+	}
+	return size
+}
+
+// checkPatchCRCs checks a UPS or BPS footer, the CRC-32s of the source,
+// target and patch, except for the target which isn't known yet. It
+// returns the body of the patch before the footer and the expected target
+// CRC.
+func checkPatchCRCs(source []byte, patch []byte) (body []byte, targetCRC uint32, err error) {
+	if len(patch) < 12 {
+		return nil, 0, errPatchTruncated
+	}
+
+	footer := patch[len(patch)-12:]
+	if crc := crc32.ChecksumIEEE(patch[:len(patch)-4]); crc != binary.LittleEndian.Uint32(footer[8:]) {
+		return nil, 0, fmt.Errorf("patch is corrupt, CRC is 0x%08X but should be 0x%08X", crc, binary.LittleEndian.Uint32(footer[8:]))
+	}
+	if crc := crc32.ChecksumIEEE(source); crc != binary.LittleEndian.Uint32(footer[0:]) {
+		return nil, 0, fmt.Errorf("patch is for a different ROM, CRC is 0x%08X but should be 0x%08X", crc, binary.LittleEndian.Uint32(footer[0:]))
+	}
+	return patch[:len(patch)-12], binary.LittleEndian.Uint32(footer[4:]), nil
+}
+
+func checkTargetCRC(target []byte, expected uint32) error {
+	if crc := crc32.ChecksumIEEE(target); crc != expected {
+		return fmt.Errorf("patched ROM is wrong, CRC is 0x%08X but should be 0x%08X", crc, expected)
+	}
+	return nil
+}
+
+// applyUPS applies a UPS patch: the source and target sizes, then hunks of
+// a relative offset followed by bytes to XOR into the ROM, ended by a 0
+func applyUPS(rom []byte, patch []byte) ([]byte, error) {
+	body, targetCRC, err := checkPatchCRCs(rom, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &patchReader{data: body, pos: 4}
+	sourceSize := r.size()
+	targetSize := r.size()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if sourceSize != len(rom) {
+		return nil, fmt.Errorf("patch is for a %d byte ROM but this one is %d", sourceSize, len(rom))
+	}
+
+	target := make([]byte, targetSize)
+	copy(target, rom)
+
+	pos := 0
+	for r.pos < len(body) && r.err == nil {
+		pos += r.size()
+		for r.err == nil {
+			x := r.byte()
+			if pos < targetSize {
+				target[pos] ^= x
+			}
+			pos++
+			if x == 0 {
+				break
+			}
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if err := checkTargetCRC(target, targetCRC); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// BPS actions, in the low 2 bits of each command
+const (
+	bpsSourceRead = iota
+	bpsTargetRead
+	bpsSourceCopy
+	bpsTargetCopy
+)
+
+// applyBPS applies a BPS patch: the source, target and metadata sizes,
+// then commands that build the target from runs of the source, the patch,
+// or earlier parts of the target
+func applyBPS(rom []byte, patch []byte) ([]byte, error) {
+	body, targetCRC, err := checkPatchCRCs(rom, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &patchReader{data: body, pos: 4}
+	sourceSize := r.size()
+	targetSize := r.size()
+	r.pos += r.size() // Metadata
+	if r.err != nil {
+		return nil, r.err
+	}
+	if sourceSize != len(rom) {
+		return nil, fmt.Errorf("patch is for a %d byte ROM but this one is %d", sourceSize, len(rom))
+	}
+
+	target := make([]byte, targetSize)
+	out, sourceRel, targetRel := 0, 0, 0
+	for r.pos < len(body) && r.err == nil {
+		command := r.number()
+		length := command>>2 + 1
+		if out+length > targetSize {
+			return nil, errors.New("patch writes past the end of the ROM")
+		}
+
+		switch command & 0x03 {
+		case bpsSourceRead:
+			if out+length > len(rom) {
+				return nil, errors.New("patch reads past the end of the ROM")
+			}
+			copy(target[out:], rom[out:out+length])
+		case bpsTargetRead:
+			if r.pos+length > len(body) {
+				return nil, errPatchTruncated
+			}
+			copy(target[out:], body[r.pos:r.pos+length])
+			r.pos += length
+		case bpsSourceCopy:
+			sourceRel += signedOffset(r.number())
+			if sourceRel < 0 || sourceRel+length > len(rom) {
+				return nil, errors.New("patch reads past the end of the ROM")
+			}
+			copy(target[out:], rom[sourceRel:sourceRel+length])
+			sourceRel += length
+		case bpsTargetCopy:
+			targetRel += signedOffset(r.number())
+			if targetRel < 0 || targetRel >= out {
+				return nil, errors.New("patch copies from outside the patched ROM")
+			}
+			// Byte by byte, as the copy can overlap what it writes
+			for i := range length {
+				target[out+i] = target[targetRel+i]
+			}
+			targetRel += length
+		}
+		out += length
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if err := checkTargetCRC(target, targetCRC); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// signedOffset decodes a BPS relative offset, whose low bit is the sign
+func signedOffset(value int) int {
+	if value&1 != 0 {
+		return -(value >> 1)
+	}
+	return value >> 1
+}
+
+// applyPatchFile applies the patch at path to rom
+func applyPatchFile(rom []byte, path string) ([]byte, error) {
+	patch, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("patch %s not found", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load patch: %w", err)
+	}
+
+	patched, err := ApplyPatch(rom, patch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply patch %s: %w", path, err)
+	}
+	return patched, nil
+}
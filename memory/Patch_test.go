@@ -0,0 +1,143 @@
+package memory
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"math"
+	"testing"
+)
+
+// patchNumber encodes a UPS or BPS variable length number
+func patchNumber(n uint64) []byte {
+	var out []byte
+	for {
+		b := byte(n & 0x7F)
+		n >>= 7
+		if n == 0 {
+			return append(out, b|0x80)
+		}
+		out = append(out, b)
+		n--
+	}
+}
+
+// buildPatch joins a signature and body and adds the CRC footer
+func buildPatch(signature string, source []byte, target []byte, body ...[]byte) []byte {
+	patch := []byte(signature)
+	for _, part := range body {
+		patch = append(patch, part...)
+	}
+	patch = binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(source))
+	patch = binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(target))
+	return binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(patch))
+}
+
+func TestPatchNumber(t *testing.T) {
+	for _, n := range []uint64{0, 1, 127, 128, 16511, 16512, maxPatchSize, math.MaxInt64} {
+		r := &patchReader{data: patchNumber(n)}
+		if got := r.number(); r.err != nil || uint64(got) != n {
+			t.Errorf("number() = %d, %v, want %d", got, r.err, n)
+		}
+	}
+}
+
+func TestPatchNumberOverflow(t *testing.T) {
+	tests := map[string][]byte{
+		"past int":      patchNumber(math.MaxInt64 + 1),
+		"past max uint": patchNumber(math.MaxUint64),
+		"too long":      append(bytes.Repeat([]byte{0x00}, 12), 0x80),
+	}
+	for name, data := range tests {
+		r := &patchReader{data: data}
+		if got := r.number(); !errors.Is(r.err, errPatchNumber) {
+			t.Errorf("%s: number() = %d, %v, want errPatchNumber", name, got, r.err)
+		}
+	}
+}
+
+func TestApplyUPS(t *testing.T) {
+	source := []byte{0x00, 0x11, 0x22, 0x33}
+	target := []byte{0x00, 0x11, 0xAA, 0x33, 0x44}
+	patch := buildPatch("UPS1", source, target,
+		patchNumber(4), patchNumber(5),
+		patchNumber(2), []byte{0x22 ^ 0xAA, 0x00},
+		patchNumber(0), []byte{0x44, 0x00},
+	)
+
+	patched, err := ApplyPatch(source, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(patched, target) {
+		t.Errorf("patched ROM is % X, want % X", patched, target)
+	}
+}
+
+func TestApplyBPS(t *testing.T) {
+	source := []byte{0x00, 0x11, 0x22, 0x33}
+	target := []byte{0x00, 0x11, 0xAA, 0x00, 0x11, 0xAA, 0x00}
+	patch := buildPatch("BPS1", source, target,
+		patchNumber(4), patchNumber(7), patchNumber(0),
+		patchNumber(1<<2|bpsSourceRead),
+		patchNumber(0<<2|bpsTargetRead), []byte{0xAA},
+		patchNumber(3<<2|bpsTargetCopy), patchNumber(0),
+	)
+
+	patched, err := ApplyPatch(source, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(patched, target) {
+		t.Errorf("patched ROM is % X, want % X", patched, target)
+	}
+}
+
+func TestApplyMalformedPatch(t *testing.T) {
+	source := []byte{0x00, 0x11, 0x22, 0x33}
+	huge := patchNumber(math.MaxInt64 + 1)
+	tests := map[string]struct {
+		patch []byte
+		err   error
+	}{
+		"UPS target too large": {
+			buildPatch("UPS1", source, nil, patchNumber(4), patchNumber(maxPatchSize+1)),
+			errPatchTooLarge,
+		},
+		"UPS target overflows": {
+			buildPatch("UPS1", source, nil, patchNumber(4), huge),
+			errPatchNumber,
+		},
+		"UPS offset too large": {
+			buildPatch("UPS1", source, nil, patchNumber(4), patchNumber(4), patchNumber(math.MaxInt64), []byte{0x01, 0x00}),
+			errPatchTooLarge,
+		},
+		"UPS truncated number": {
+			buildPatch("UPS1", source, nil, patchNumber(4), []byte{0x00, 0x00}),
+			errPatchTruncated,
+		},
+		"BPS source too large": {
+			buildPatch("BPS1", source, nil, patchNumber(1<<40), patchNumber(4), patchNumber(0)),
+			errPatchTooLarge,
+		},
+		"BPS target overflows": {
+			buildPatch("BPS1", source, nil, patchNumber(4), huge, patchNumber(0)),
+			errPatchNumber,
+		},
+		"BPS metadata too large": {
+			buildPatch("BPS1", source, nil, patchNumber(4), patchNumber(4), patchNumber(maxPatchSize+1)),
+			errPatchTooLarge,
+		},
+		"BPS command overflows": {
+			buildPatch("BPS1", source, nil, patchNumber(4), patchNumber(4), patchNumber(0), huge),
+			errPatchNumber,
+		},
+	}
+
+	for name, test := range tests {
+		if _, err := ApplyPatch(source, test.patch); !errors.Is(err, test.err) {
+			t.Errorf("%s: error is %v, want %v", name, err, test.err)
+		}
+	}
+}
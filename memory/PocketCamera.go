@@ -0,0 +1,246 @@
+package memory
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+)
+
+// Pocket Camera sensor and output image size, in pixels
+const (
+	cameraWidth  = 128
+	cameraHeight = 112
+)
+
+// Captured images land in RAM bank 0 as 16x14 tiles from this offset
+const cameraImageOffset = 0x0100
+
+// CameraSource supplies the images the Pocket Camera sensor sees
+type CameraSource interface {
+	// Frame returns the current image. It is scaled to the sensor's
+	// 128x112 and converted to grayscale.
+	Frame() image.Image
+}
+
+// cameraCartridge is implemented by controllers with an image sensor
+type cameraCartridge interface {
+	setCameraSource(source CameraSource)
+}
+
+// pocketCamera is the Game Boy Camera controller. It banks ROM and up to
+// 128KB of RAM like an MBC3, and RAM bank 0x10 selects the registers of the
+// sensor, which writes captured images into RAM as tiles.
+//
+// The sensor's analogue processing (gain, edge enhancement, exposure) is
+// not emulated. Captures only go through the dithering matrix the game
+// programs, which is what sets the brightness and contrast of the result.
+type pocketCamera struct {
+	cartMemory
+	ramEnabled bool // Enables writes only, RAM is always readable
+	romBank    byte
+	ramBank    byte
+	registers  [0x36]byte
+	busyTicks  int // Ticks until the capture in progress finishes
+	source     CameraSource
+}
+
+func newPocketCamera(banks cartMemory) *pocketCamera {
+	return &pocketCamera{cartMemory: banks, romBank: 1}
+}
+
+func (mbc *pocketCamera) ReadROM(addr uint16) byte {
+	if addr < 0x4000 {
+		return mbc.romByte(0, addr)
+	}
+	return mbc.romByte(int(mbc.romBank), addr)
+}
+
+func (mbc *pocketCamera) cameraSelected() bool {
+	return mbc.ramBank&0x10 != 0
+}
+
+func (mbc *pocketCamera) ReadRAM(addr uint16) byte {
+	if mbc.cameraSelected() {
+		// Only the status register can be read back
+		if addr&0x7F == 0 {
+			return mbc.registers[0]
+		}
+		return 0x00
+	}
+	return mbc.ramByte(int(mbc.ramBank), addr)
+}
+
+func (mbc *pocketCamera) WriteRAM(addr uint16, value byte) {
+	if mbc.cameraSelected() {
+		// Registers are mirrored every 0x80 bytes
+		register := int(addr & 0x7F)
+		if register >= len(mbc.registers) {
+			return
+		}
+		if register == 0 {
+			mbc.writeStatus(value)
+			return
+		}
+		mbc.registers[register] = value
+		return
+	}
+
+	// The game can't write RAM while a capture is filling it
+	if mbc.ramEnabled && mbc.busyTicks == 0 {
+		mbc.setRAMByte(int(mbc.ramBank), addr, value)
+	}
+}
+
+// writeStatus handles register 0. Bit 0 starts a capture and reads as set
+// until it finishes.
+func (mbc *pocketCamera) writeStatus(value byte) {
+	busy := mbc.registers[0] & 0x01
+	mbc.registers[0] = value&0x06 | busy
+
+	if value&0x01 != 0 && busy == 0 {
+		mbc.registers[0] |= 0x01
+		mbc.busyTicks = mbc.captureTicks()
+	} else if value&0x01 == 0 && busy != 0 {
+		// Clearing the bit cancels the capture
+		mbc.registers[0] &^= 0x01
+		mbc.busyTicks = 0
+	}
+}
+
+// captureTicks returns how long a capture takes, which depends on the
+// exposure time and the N bit of register 1
+func (mbc *pocketCamera) captureTicks() int {
+	cycles := 32446 + 16*(int(mbc.registers[2])<<8|int(mbc.registers[3]))
+	if mbc.registers[1]&0x80 == 0 {
+		cycles += 512
+	}
+	// Cycles of the 1MHz sensor clock, 2 ticks each
+	return cycles * 2
+}
+
+func (mbc *pocketCamera) WriteControl(addr uint16, value byte) {
+	if addr < 0x2000 {
+		// RAM write enable
+		mbc.ramEnabled = value&0x0F == 0x0A
+	} else if addr < 0x4000 {
+		// ROM bank number
+		mbc.romBank = value & 0x3F
+	} else if addr < 0x6000 {
+		// RAM bank number, or 0x10 for the camera registers
+		mbc.ramBank = value & 0x1F
+	}
+}
+
+func (mbc *pocketCamera) tick(rtcTicks int) {
+	if mbc.busyTicks == 0 {
+		return
+	}
+
+	mbc.busyTicks -= rtcTicks
+	if mbc.busyTicks <= 0 {
+		mbc.busyTicks = 0
+		mbc.capture()
+		mbc.registers[0] &^= 0x01
+	}
+}
+
+// capture takes a frame from the source and writes it to RAM bank 0 as
+// 2bpp tiles, dithered through the matrix in registers 0x06-0x35
+func (mbc *pocketCamera) capture() {
+	if len(mbc.ram) < cameraImageOffset+cameraWidth*cameraHeight/4 {
+		return
+	}
+
+	pixels := mbc.sensorPixels()
+	tiles := mbc.ram[cameraImageOffset:]
+	for i := range cameraWidth * cameraHeight / 4 {
+		tiles[i] = 0
+	}
+
+	for y := range cameraHeight {
+		for x := range cameraWidth {
+			// Each cell of the 4x4 matrix holds three thresholds, the
+			// darker a pixel the higher the colour number
+			threshold := 6 + ((y&3)*4+(x&3))*3
+			value := pixels[y*cameraWidth+x]
+			colour := byte(0)
+			for _, level := range mbc.registers[threshold : threshold+3] {
+				if value < level {
+					colour++
+				}
+			}
+
+			tile := (y/8)*(cameraWidth/8) + x/8
+			row := tile*16 + (y%8)*2
+			bit := byte(0x80) >> (x % 8)
+			if colour&0x01 != 0 {
+				tiles[row] |= bit
+			}
+			if colour&0x02 != 0 {
+				tiles[row+1] |= bit
+			}
+		}
+	}
+}
+
+// sensorPixels returns the source's frame as 128x112 8-bit grayscale, or a
+// black frame, as with the lens covered, when there is no source
+func (mbc *pocketCamera) sensorPixels() []byte {
+	pixels := make([]byte, cameraWidth*cameraHeight)
+	if mbc.source == nil {
+		return pixels
+	}
+
+	frame := mbc.source.Frame()
+	if frame == nil {
+		return pixels
+	}
+
+	bounds := frame.Bounds()
+	if bounds.Empty() {
+		return pixels
+	}
+
+	for y := range cameraHeight {
+		for x := range cameraWidth {
+			// Nearest neighbour scaling
+			srcX := bounds.Min.X + x*bounds.Dx()/cameraWidth
+			srcY := bounds.Min.Y + y*bounds.Dy()/cameraHeight
+			gray := color.GrayModel.Convert(frame.At(srcX, srcY)).(color.Gray)
+			pixels[y*cameraWidth+x] = gray.Y
+		}
+	}
+	return pixels
+}
+
+func (mbc *pocketCamera) setCameraSource(source CameraSource) {
+	mbc.source = source
+}
+
+// cameraStateSize is the controller registers, the sensor registers and
+// the remaining capture time
+const cameraStateSize = 3 + 0x36 + 4
+
+func (mbc *pocketCamera) MarshalBinary() ([]byte, error) {
+	state := make([]byte, cameraStateSize)
+	state[0] = boolToByte(mbc.ramEnabled)
+	state[1] = mbc.romBank
+	state[2] = mbc.ramBank
+	copy(state[3:0x39], mbc.registers[:])
+	binary.LittleEndian.PutUint32(state[0x39:], uint32(mbc.busyTicks))
+	return mbc.marshalState(state...), nil
+}
+
+func (mbc *pocketCamera) UnmarshalBinary(data []byte) error {
+	state, err := mbc.unmarshalState(data, cameraStateSize)
+	if err != nil {
+		return err
+	}
+
+	mbc.ramEnabled = state[0] != 0
+	mbc.romBank = state[1]
+	mbc.ramBank = state[2]
+	copy(mbc.registers[:], state[3:0x39])
+	mbc.busyTicks = int(binary.LittleEndian.Uint32(state[0x39:]))
+	return nil
+}
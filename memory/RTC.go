@@ -0,0 +1,187 @@
+package memory
+
+import "time"
+
+// RTCClock selects what drives a cartridge's real-time clock
+type RTCClock int
+
+const (
+	// RTCWallClock follows the host's clock, so the cartridge keeps time
+	// like the real one would, including while the emulator is closed
+	RTCWallClock RTCClock = iota
+	// RTCEmulatedClock advances only with emulated cycles passed to
+	// Memory.Tick, making runs deterministic
+	RTCEmulatedClock
+)
+
+// rtcTicksPerSecond is the resolution of the emulated clock. A normal speed
+// M-cycle is 2 ticks and a double speed one is 1, so the clock keeps real
+// time whatever speed the CPU runs at.
+const rtcTicksPerSecond = 1 << 21
+
+// rtc is the MBC3 real-time clock. Reads go through a latched copy of the
+// counters, taken on the latch sequence.
+type rtc struct {
+	seconds byte
+	minutes byte
+	hours   byte
+	days    uint16 // 9-bit day counter
+	halted  bool
+	carry   bool // Day counter overflowed
+	latched [5]byte
+
+	clock    RTCClock
+	ticks    int64     // Emulated clock, ticks towards the next second
+	lastSync time.Time // Wall clock, time the counters were last brought up to date
+}
+
+func newRTC() *rtc {
+	return &rtc{lastSync: time.Now()}
+}
+
+func (r *rtc) setClock(clock RTCClock) {
+	r.sync()
+	r.clock = clock
+	r.ticks = 0
+	r.lastSync = time.Now()
+}
+
+// tick advances the emulated clock
+func (r *rtc) tick(ticks int) {
+	if r.clock != RTCEmulatedClock || r.halted {
+		return
+	}
+
+	r.ticks += int64(ticks)
+	if r.ticks >= rtcTicksPerSecond {
+		r.advance(r.ticks / rtcTicksPerSecond)
+		r.ticks %= rtcTicksPerSecond
+	}
+}
+
+// sync brings the wall clock driven counters up to date
+func (r *rtc) sync() {
+	if r.clock != RTCWallClock {
+		return
+	}
+
+	now := time.Now()
+	if r.halted {
+		r.lastSync = now
+		return
+	}
+
+	elapsed := int64(now.Sub(r.lastSync) / time.Second)
+	if elapsed > 0 {
+		r.advance(elapsed)
+		r.lastSync = r.lastSync.Add(time.Duration(elapsed) * time.Second)
+	}
+}
+
+// advance moves the counters forward by the given number of seconds
+func (r *rtc) advance(seconds int64) {
+	// Counters written with out of range values count up to their bit
+	// width before wrapping, without carrying into the next counter
+	for seconds > 0 && (r.seconds >= 60 || r.minutes >= 60 || r.hours >= 24) {
+		r.incrementSecond()
+		seconds--
+	}
+	if seconds == 0 {
+		return
+	}
+
+	total := int64(r.seconds) + int64(r.minutes)*60 + int64(r.hours)*3600 + int64(r.days)*86400 + seconds
+	r.seconds = byte(total % 60)
+	r.minutes = byte(total / 60 % 60)
+	r.hours = byte(total / 3600 % 24)
+
+	days := total / 86400
+	if days >= 512 {
+		r.carry = true
+		days %= 512
+	}
+	r.days = uint16(days)
+}
+
+func (r *rtc) incrementSecond() {
+	if r.seconds != 59 {
+		r.seconds = (r.seconds + 1) & 0x3F
+		return
+	}
+	r.seconds = 0
+
+	if r.minutes != 59 {
+		r.minutes = (r.minutes + 1) & 0x3F
+		return
+	}
+	r.minutes = 0
+
+	if r.hours != 23 {
+		r.hours = (r.hours + 1) & 0x1F
+		return
+	}
+	r.hours = 0
+
+	r.days++
+	if r.days == 512 {
+		r.days = 0
+		r.carry = true
+	}
+}
+
+// latch copies the live counters into the registers the game reads
+func (r *rtc) latch() {
+	r.sync()
+	r.latched = r.registers()
+}
+
+// registers returns S, M, H, DL and DH as the game sees them
+func (r *rtc) registers() [5]byte {
+	dh := byte(r.days>>8) & 0x01
+	if r.halted {
+		dh |= 0x40
+	}
+	if r.carry {
+		dh |= 0x80
+	}
+	return [5]byte{r.seconds, r.minutes, r.hours, byte(r.days), dh}
+}
+
+// read returns a latched register, selected by 0x08-0x0C
+func (r *rtc) read(register byte) byte {
+	return r.latched[register-0x08]
+}
+
+// write sets a live register, selected by 0x08-0x0C
+func (r *rtc) write(register byte, value byte) {
+	r.sync()
+
+	switch register {
+	case 0x08:
+		r.seconds = value & 0x3F
+		// Writing the seconds restarts the current second
+		r.ticks = 0
+		r.lastSync = time.Now()
+	case 0x09:
+		r.minutes = value & 0x3F
+	case 0x0A:
+		r.hours = value & 0x1F
+	case 0x0B:
+		r.days = r.days&0x100 | uint16(value)
+	case 0x0C:
+		r.days = r.days&0xFF | uint16(value&0x01)<<8
+		r.halted = value&0x40 != 0
+		r.carry = value&0x80 != 0
+	}
+}
+
+// resume catches the clock up with the time that passed since a save was
+// written. The emulated clock only counts cycles it ran, so it ignores
+// this.
+func (r *rtc) resume(saved time.Time) {
+	if r.clock != RTCWallClock || saved.After(time.Now()) {
+		return
+	}
+	r.lastSync = saved
+	r.sync()
+}
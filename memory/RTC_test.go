@@ -0,0 +1,184 @@
+package memory
+
+import "testing"
+
+// RTC registers, as selected by writing them to 0x4000-0x5FFF
+const (
+	rtcS  = 0x08
+	rtcM  = 0x09
+	rtcH  = 0x0A
+	rtcDL = 0x0B
+	rtcDH = 0x0C
+)
+
+// cyclesPerSecond is the normal speed M-cycles in one second of the clock
+const cyclesPerSecond = rtcTicksPerSecond / 2
+
+// newRTCMemory returns memory for an MBC3+TIMER+RAM+BATTERY cartridge with
+// its clock driven by emulated cycles and RAM enabled
+func newRTCMemory(t *testing.T, model Model) *Memory {
+	t.Helper()
+
+	rom := newBankedROM(4, 0x10, 0x02)
+	rom[addrCGBFlag] = 0x80
+	mem := newTestMemoryFromROM(t, model, rom)
+	mem.SetRTCClock(RTCEmulatedClock)
+	mem.Write(0x0000, 0x0A)
+	return mem
+}
+
+func writeRTC(mem *Memory, register byte, value byte) {
+	mem.Write(0x4000, register)
+	mem.Write(0xA000, value)
+}
+
+func readRTC(mem *Memory, register byte) byte {
+	mem.Write(0x4000, register)
+	return mem.Read(0xA000)
+}
+
+func latchRTC(mem *Memory) {
+	mem.Write(0x6000, 0x00)
+	mem.Write(0x6000, 0x01)
+}
+
+func TestRTCLatch(t *testing.T) {
+	mem := newRTCMemory(t, ModelDMG)
+	writeRTC(mem, rtcS, 5)
+	if got := readRTC(mem, rtcS); got != 0 {
+		t.Errorf("S reads %d before latching, want the latched 0", got)
+	}
+
+	latchRTC(mem)
+	mem.Tick(cyclesPerSecond)
+	if got := readRTC(mem, rtcS); got != 5 {
+		t.Errorf("S reads %d, want 5 latched before the second passed", got)
+	}
+
+	// 0x01 without 0x00 before it doesn't latch
+	mem.Write(0x6000, 0x01)
+	if got := readRTC(mem, rtcS); got != 5 {
+		t.Errorf("S reads %d after writing 0x01 alone, want still 5", got)
+	}
+
+	latchRTC(mem)
+	if got := readRTC(mem, rtcS); got != 6 {
+		t.Errorf("S reads %d after latching, want 6", got)
+	}
+}
+
+func TestRTCHalt(t *testing.T) {
+	mem := newRTCMemory(t, ModelDMG)
+	writeRTC(mem, rtcS, 10)
+	writeRTC(mem, rtcDH, 0x40)
+	mem.Tick(5 * cyclesPerSecond)
+
+	latchRTC(mem)
+	if got := readRTC(mem, rtcS); got != 10 {
+		t.Errorf("S reads %d while halted, want 10", got)
+	}
+	if got := readRTC(mem, rtcDH); got&0x40 == 0 {
+		t.Errorf("DH reads 0x%02X, want the halt bit set", got)
+	}
+
+	writeRTC(mem, rtcDH, 0x00)
+	mem.Tick(cyclesPerSecond)
+	latchRTC(mem)
+	if got := readRTC(mem, rtcS); got != 11 {
+		t.Errorf("S reads %d after restarting, want 11", got)
+	}
+}
+
+func TestRTCDayCarry(t *testing.T) {
+	mem := newRTCMemory(t, ModelDMG)
+	writeRTC(mem, rtcS, 59)
+	writeRTC(mem, rtcM, 59)
+	writeRTC(mem, rtcH, 23)
+	writeRTC(mem, rtcDL, 0xFF)
+	writeRTC(mem, rtcDH, 0x01) // Day 511
+	mem.Tick(cyclesPerSecond)
+
+	latchRTC(mem)
+	want := map[byte]byte{rtcS: 0, rtcM: 0, rtcH: 0, rtcDL: 0, rtcDH: 0x80}
+	for register, value := range want {
+		if got := readRTC(mem, register); got != value {
+			t.Errorf("register 0x%02X reads 0x%02X after day 511, want 0x%02X", register, got, value)
+		}
+	}
+
+	// The carry stays set until the game clears it
+	mem.Tick(cyclesPerSecond)
+	latchRTC(mem)
+	if got := readRTC(mem, rtcDH); got != 0x80 {
+		t.Errorf("DH reads 0x%02X a second later, want the carry still set", got)
+	}
+	writeRTC(mem, rtcDH, 0x00)
+	latchRTC(mem)
+	if got := readRTC(mem, rtcDH); got != 0x00 {
+		t.Errorf("DH reads 0x%02X after clearing, want 0x00", got)
+	}
+}
+
+func TestRTCOutOfRangeValues(t *testing.T) {
+	tests := []struct {
+		name          string
+		s, m, h       byte
+		seconds       int
+		wantS, wantM  byte
+		wantH, wantDL byte
+	}{
+		// Counters count up to their bit width, then wrap to 0 without
+		// carrying into the next one
+		{"seconds past 59", 62, 5, 0, 1, 63, 5, 0, 0},
+		{"seconds wrap", 62, 5, 0, 2, 0, 5, 0, 0},
+		{"minutes wrap", 59, 63, 0, 1, 0, 0, 0, 0},
+		{"hours wrap", 59, 59, 31, 1, 0, 0, 0, 0},
+		{"hours past 23", 59, 59, 24, 1, 0, 0, 25, 0},
+		{"wrap doesn't carry", 63, 59, 23, 2, 1, 59, 23, 0},
+	}
+
+	for _, test := range tests {
+		mem := newRTCMemory(t, ModelDMG)
+		writeRTC(mem, rtcS, test.s)
+		writeRTC(mem, rtcM, test.m)
+		writeRTC(mem, rtcH, test.h)
+		mem.Tick(test.seconds * cyclesPerSecond)
+
+		latchRTC(mem)
+		got := [4]byte{readRTC(mem, rtcS), readRTC(mem, rtcM), readRTC(mem, rtcH), readRTC(mem, rtcDL)}
+		want := [4]byte{test.wantS, test.wantM, test.wantH, test.wantDL}
+		if got != want {
+			t.Errorf("%s: S, M, H, DL are %v, want %v", test.name, got, want)
+		}
+	}
+}
+
+func TestRTCEmulatedTicks(t *testing.T) {
+	tests := []struct {
+		name        string
+		doubleSpeed bool
+		cycles      int // M-cycles in one second
+	}{
+		{"normal speed", false, rtcTicksPerSecond / 2},
+		{"double speed", true, rtcTicksPerSecond},
+	}
+
+	for _, test := range tests {
+		mem := newRTCMemory(t, ModelCGB)
+		if test.doubleSpeed {
+			mem.SwitchSpeed()
+		}
+
+		mem.Tick(test.cycles - 1)
+		latchRTC(mem)
+		if got := readRTC(mem, rtcS); got != 0 {
+			t.Errorf("%s: S reads %d a cycle short of a second, want 0", test.name, got)
+		}
+
+		mem.Tick(1)
+		latchRTC(mem)
+		if got := readRTC(mem, rtcS); got != 1 {
+			t.Errorf("%s: S reads %d after %d M-cycles, want 1", test.name, got, test.cycles)
+		}
+	}
+}
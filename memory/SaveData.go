@@ -0,0 +1,124 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// batteryTypes are the cartridge types whose RAM, or flash, EEPROM or
+// clock, is kept alive by a battery
+var batteryTypes = map[byte]bool{
+	0x03: true, // MBC1+RAM+BATTERY
+	0x06: true, // MBC2+BATTERY
+	0x09: true, // ROM+RAM+BATTERY
+	0x0D: true, // MMM01+RAM+BATTERY
+	0x0F: true, // MBC3+TIMER+BATTERY
+	0x10: true, // MBC3+TIMER+RAM+BATTERY
+	0x13: true, // MBC3+RAM+BATTERY
+	0x1B: true, // MBC5+RAM+BATTERY
+	0x1E: true, // MBC5+RUMBLE+RAM+BATTERY
+	0x20: true, // MBC6
+	0x22: true, // MBC7+SENSOR+RUMBLE+RAM+BATTERY
+	0xFC: true, // POCKET CAMERA
+	0xFE: true, // HuC3
+	0xFF: true, // HuC1+RAM+BATTERY
+}
+
+// saveDataCartridge is implemented by controllers whose save file holds
+// more than their RAM, such as a clock
+type saveDataCartridge interface {
+	saveData() []byte
+	loadSaveData(data []byte) error
+}
+
+// saveData returns the RAM contents, the save file for most cartridges
+func (c *cartMemory) saveData() []byte {
+	return append([]byte(nil), c.ram...)
+}
+
+// loadSaveData restores the RAM from a save file. Files of the wrong size
+// are loaded as far as they go, as other emulators pad or trim some saves.
+func (c *cartMemory) loadSaveData(data []byte) error {
+	copy(c.ram, data)
+	return nil
+}
+
+// HasBattery reports whether the cartridge keeps its save data when the
+// power is off, and so whether it needs a save file
+func (mem *Memory) HasBattery() bool {
+	return batteryTypes[mem.cartridge.hardwareHeader().CartridgeType]
+}
+
+// SaveData returns the cartridge's battery backed data in the .sav format
+// other emulators use: the raw RAM, followed by a footer for clocks
+func (mem *Memory) SaveData() []byte {
+	if cart, ok := mem.mbc.(saveDataCartridge); ok {
+		return cart.saveData()
+	}
+	return nil
+}
+
+// LoadSaveData restores the cartridge's battery backed data from the
+// contents of a .sav file
+func (mem *Memory) LoadSaveData(data []byte) error {
+	if cart, ok := mem.mbc.(saveDataCartridge); ok {
+		return cart.loadSaveData(data)
+	}
+	return nil
+}
+
+// SavePath returns the save file that belongs next to a ROM, which has the
+// same name with a .sav extension. The extension of a compressed ROM, as
+// in game.gb.gz, is dropped along with the archive's.
+func SavePath(romPath string) string {
+	return trimROMExt(romPath) + ".sav"
+}
+
+// trimROMExt removes a ROM file's extension, and an archive's
+func trimROMExt(romPath string) string {
+	if strings.EqualFold(filepath.Ext(romPath), ".gz") {
+		romPath = strings.TrimSuffix(romPath, filepath.Ext(romPath))
+	}
+	return strings.TrimSuffix(romPath, filepath.Ext(romPath))
+}
+
+// LoadSaveFile restores the cartridge's battery backed data from a .sav
+// file. A missing file is not an error, the game just starts without a
+// save.
+func (mem *Memory) LoadSaveFile(path string) error {
+	if !mem.HasBattery() {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load save: %w", err)
+	}
+	return mem.LoadSaveData(data)
+}
+
+// WriteSaveFile writes the cartridge's battery backed data to a .sav file.
+// The file is replaced in one step, so a crash midway never leaves a
+// truncated save behind.
+func (mem *Memory) WriteSaveFile(path string) error {
+	if !mem.HasBattery() {
+		return nil
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, mem.SaveData(), 0o644); err != nil {
+		return fmt.Errorf("failed to write save: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write save: %w", err)
+	}
+	return nil
+}
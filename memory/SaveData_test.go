@@ -0,0 +1,175 @@
+package memory
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// newMBC3Memory returns memory for an MBC3 cartridge with 8KB of battery
+// backed RAM, enabled, and a clock if rtc is set
+func newMBC3Memory(t *testing.T, rtc bool) *Memory {
+	t.Helper()
+
+	cartridgeType := byte(0x13) // MBC3+RAM+BATTERY
+	if rtc {
+		cartridgeType = 0x10 // MBC3+TIMER+RAM+BATTERY
+	}
+	mem := newTestMemoryFromROM(t, ModelDMG, newBankedROM(4, cartridgeType, 0x02))
+	mem.Write(0x0000, 0x0A)
+	return mem
+}
+
+// rtcFooter returns a save file footer with the given live and latched
+// registers, without its timestamp
+func rtcFooter(live, latched [5]byte) []byte {
+	footer := make([]byte, 40)
+	for i := range live {
+		binary.LittleEndian.PutUint32(footer[i*4:], uint32(live[i]))
+		binary.LittleEndian.PutUint32(footer[20+i*4:], uint32(latched[i]))
+	}
+	return footer
+}
+
+// rtcRegisters returns S, M, H, DL and DH as latched
+func rtcRegisters(mem *Memory) [5]byte {
+	var registers [5]byte
+	for i := range registers {
+		registers[i] = readRTC(mem, rtcS+byte(i))
+	}
+	return registers
+}
+
+func TestMBC3SaveDataRoundTrip(t *testing.T) {
+	mem := newMBC3Memory(t, true)
+	mem.SetRTCClock(RTCEmulatedClock)
+	mem.Write(0x4000, 0x00)
+	mem.Write(0xA000, 0x12)
+	mem.Write(0xBFFF, 0x34)
+
+	live := [5]byte{12, 34, 5, 0x23, 0x01}
+	for i, value := range live {
+		writeRTC(mem, rtcS+byte(i), value)
+	}
+	latchRTC(mem)
+	writeRTC(mem, rtcS, 40) // Live only, the latched S stays 12
+
+	data := mem.SaveData()
+	if len(data) != 8*1024+rtcFooterSize {
+		t.Fatalf("save is %d bytes, want 8KB of RAM and a %d byte footer", len(data), rtcFooterSize)
+	}
+
+	loaded := newMBC3Memory(t, true)
+	loaded.SetRTCClock(RTCEmulatedClock)
+	if err := loaded.LoadSaveData(data); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded.Write(0x4000, 0x00)
+	if loaded.Read(0xA000) != 0x12 || loaded.Read(0xBFFF) != 0x34 {
+		t.Error("RAM not restored")
+	}
+	if got := rtcRegisters(loaded); got != live {
+		t.Errorf("latched registers are %v, want %v", got, live)
+	}
+	// All but the timestamp, which is when the file is written
+	if resaved := loaded.SaveData(); !bytes.Equal(resaved[:len(resaved)-8], data[:len(data)-8]) {
+		t.Error("saving again gives a different file")
+	}
+
+	latchRTC(loaded)
+	if got, want := rtcRegisters(loaded), [5]byte{40, 34, 5, 0x23, 0x01}; got != want {
+		t.Errorf("live registers are %v, want %v", got, want)
+	}
+}
+
+func TestMBC3LoadSaveDataCatchesUp(t *testing.T) {
+	registers := [5]byte{0, 0, 5, 0, 0}
+	hourAgo := time.Now().Add(-time.Hour).Unix()
+
+	tests := map[string][]byte{
+		"48 byte footer": binary.LittleEndian.AppendUint64(rtcFooter(registers, registers), uint64(hourAgo)),
+		"44 byte footer": binary.LittleEndian.AppendUint32(rtcFooter(registers, registers), uint32(hourAgo)),
+	}
+
+	for name, footer := range tests {
+		ram := bytes.Repeat([]byte{0x5A}, 8*1024)
+		mem := newMBC3Memory(t, true)
+		if err := mem.LoadSaveData(append(ram, footer...)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		mem.Write(0x4000, 0x00)
+		if got := mem.Read(0xA000); got != 0x5A {
+			t.Errorf("%s: RAM reads 0x%02X, want 0x5A", name, got)
+		}
+		// The wall clock ran on for the hour since the file was written
+		latchRTC(mem)
+		if got := readRTC(mem, rtcH); got != 6 {
+			t.Errorf("%s: H reads %d, want 6 an hour after 5", name, got)
+		}
+	}
+}
+
+func TestLoadSaveDataWithoutRTC(t *testing.T) {
+	mem := newMBC3Memory(t, false)
+	ram := bytes.Repeat([]byte{0xA5}, 8*1024)
+	footer := binary.LittleEndian.AppendUint64(rtcFooter([5]byte{1, 2, 3, 4, 0}, [5]byte{}), 0)
+
+	// The footer is ignored, and doesn't spill into the RAM
+	if err := mem.LoadSaveData(append(ram, footer...)); err != nil {
+		t.Fatal(err)
+	}
+	if got := mem.SaveData(); !bytes.Equal(got, ram) {
+		t.Errorf("save is %d bytes after loading one with a footer, want the 8KB of RAM", len(got))
+	}
+}
+
+func TestLoadSaveDataWrongSize(t *testing.T) {
+	tests := []struct {
+		name string
+		rtc  bool
+		size int
+	}{
+		{"short", false, 2 * 1024},
+		{"long", false, 16 * 1024},
+		{"short with RTC", true, 2 * 1024},
+		{"odd footer with RTC", true, 8*1024 + 10},
+	}
+
+	for _, test := range tests {
+		mem := newMBC3Memory(t, test.rtc)
+		mem.SetRTCClock(RTCEmulatedClock)
+		mem.Write(0x4000, 0x00)
+		mem.Write(0xBFFF, 0x77)
+		if test.rtc {
+			writeRTC(mem, rtcH, 9)
+		}
+
+		if err := mem.LoadSaveData(bytes.Repeat([]byte{0x11}, test.size)); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		// Loaded as far as it goes, the rest of RAM is left alone
+		mem.Write(0x4000, 0x00)
+		if got := mem.Read(0xA000); got != 0x11 {
+			t.Errorf("%s: 0xA000 reads 0x%02X, want 0x11 from the file", test.name, got)
+		}
+		want := byte(0x11)
+		if test.size < 8*1024 {
+			want = 0x77
+		}
+		if got := mem.Read(0xBFFF); got != want {
+			t.Errorf("%s: 0xBFFF reads 0x%02X, want 0x%02X", test.name, got, want)
+		}
+
+		if test.rtc {
+			latchRTC(mem)
+			if got := readRTC(mem, rtcH); got != 9 {
+				t.Errorf("%s: H reads %d, want the clock left at 9", test.name, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Package romdb identifies ROM images by their hashes, using DAT files in
+// the Logiqx XML format No-Intro publishes. A small database is built in
+// and full DATs can be loaded on top of it.
+package romdb
+
+import (
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//go:embed GoBoy.dat
+var embeddedDAT []byte
+
+// Entry is a known ROM
+type Entry struct {
+	Name    string // Canonical name
+	Region  string // Such as "USA, Europe", empty when the name has none
+	BadDump bool   // Known to be a bad dump
+	Size    int
+	CRC32   uint32
+	SHA1    []byte // May be empty
+	// Overrides corrects header fields the ROM gets wrong
+	Overrides Overrides
+}
+
+// Overrides are header fields to use instead of the ROM's. A nil field
+// keeps the ROM's value.
+type Overrides struct {
+	CartridgeType *byte
+	ROMSize       *byte // Header code, as at 0x0148
+	RAMSize       *byte // Header code, as at 0x0149
+}
+
+// Database is a set of known ROMs
+type Database struct {
+	entries map[uint32][]Entry // By CRC32
+}
+
+// Embedded returns a new database holding the built-in entries
+func Embedded() *Database {
+	db := &Database{entries: map[uint32][]Entry{}}
+	if err := db.parse(embeddedDAT); err != nil {
+		panic(fmt.Sprintf("invalid built-in ROM database: %v", err))
+	}
+	return db
+}
+
+// LoadFile adds the entries of a DAT file, replacing any for the same ROM
+// already in the database
+func (db *Database) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to load ROM database: %w", err)
+	}
+	if err := db.parse(data); err != nil {
+		return fmt.Errorf("invalid ROM database %s: %w", path, err)
+	}
+	return nil
+}
+
+// Lookup returns the entry for a ROM image. Entries are matched by CRC32
+// and size, and by SHA-1 when the entry has one.
+func (db *Database) Lookup(rom []byte) (Entry, bool) {
+	crc := crc32.ChecksumIEEE(rom)
+	candidates := db.entries[crc]
+	if len(candidates) == 0 {
+		return Entry{}, false
+	}
+
+	sum := sha1.Sum(rom)
+	// Later entries were loaded later, so they take precedence
+	for i := len(candidates) - 1; i >= 0; i-- {
+		entry := candidates[i]
+		if entry.Size != len(rom) {
+			continue
+		}
+		if len(entry.SHA1) > 0 && !bytes.Equal(entry.SHA1, sum[:]) {
+			continue
+		}
+		return entry, true
+	}
+	return Entry{}, false
+}
+
+// Logiqx DAT layout
+type datFile struct {
+	Games []datGame `xml:"game"`
+}
+
+type datGame struct {
+	Name     string       `xml:"name,attr"`
+	ROMs     []datROM     `xml:"rom"`
+	Override *datOverride `xml:"override"`
+}
+
+type datROM struct {
+	Size   string `xml:"size,attr"`
+	CRC    string `xml:"crc,attr"`
+	SHA1   string `xml:"sha1,attr"`
+	Status string `xml:"status,attr"`
+}
+
+type datOverride struct {
+	CartridgeType string `xml:"cartridge_type,attr"`
+	ROMSize       string `xml:"rom_size,attr"`
+	RAMSize       string `xml:"ram_size,attr"`
+}
+
+func (db *Database) parse(data []byte) error {
+	var dat datFile
+	if err := xml.Unmarshal(data, &dat); err != nil {
+		return err
+	}
+
+	for _, game := range dat.Games {
+		overrides, err := game.Override.parse()
+		if err != nil {
+			return fmt.Errorf("game %q: %w", game.Name, err)
+		}
+
+		for _, rom := range game.ROMs {
+			entry, err := rom.parse()
+			if err != nil {
+				return fmt.Errorf("game %q: %w", game.Name, err)
+			}
+			entry.Name = game.Name
+			entry.Region = parseRegion(game.Name)
+			entry.BadDump = entry.BadDump || strings.Contains(game.Name, "[b]")
+			entry.Overrides = overrides
+			db.entries[entry.CRC32] = append(db.entries[entry.CRC32], entry)
+		}
+	}
+	return nil
+}
+
+func (rom datROM) parse() (Entry, error) {
+	size, err := strconv.Atoi(rom.Size)
+	if err != nil {
+		return Entry{}, fmt.Errorf("invalid size %q", rom.Size)
+	}
+	crc, err := strconv.ParseUint(rom.CRC, 16, 32)
+	if err != nil {
+		return Entry{}, fmt.Errorf("invalid crc %q", rom.CRC)
+	}
+
+	entry := Entry{Size: size, CRC32: uint32(crc), BadDump: rom.Status == "baddump"}
+	if rom.SHA1 != "" {
+		entry.SHA1, err = hex.DecodeString(rom.SHA1)
+		if err != nil || len(entry.SHA1) != sha1.Size {
+			return Entry{}, fmt.Errorf("invalid sha1 %q", rom.SHA1)
+		}
+	}
+	return entry, nil
+}
+
+func (override *datOverride) parse() (Overrides, error) {
+	var overrides Overrides
+	if override == nil {
+		return overrides, nil
+	}
+
+	fields := []struct {
+		name  string
+		value string
+		dest  **byte
+	}{
+		{"cartridge_type", override.CartridgeType, &overrides.CartridgeType},
+		{"rom_size", override.ROMSize, &overrides.ROMSize},
+		{"ram_size", override.RAMSize, &overrides.RAMSize},
+	}
+	for _, field := range fields {
+		if field.value == "" {
+			continue
+		}
+		value, err := strconv.ParseUint(strings.TrimPrefix(field.value, "0x"), 16, 8)
+		if err != nil {
+			return overrides, fmt.Errorf("invalid %s override %q", field.name, field.value)
+		}
+		b := byte(value)
+		*field.dest = &b
+	}
+	return overrides, nil
+}
+
+// regions are the names No-Intro uses for regions and countries
+var regions = map[string]bool{
+	"World": true, "USA": true, "Europe": true, "Japan": true, "Asia": true,
+	"Australia": true, "Brazil": true, "Canada": true, "China": true,
+	"France": true, "Germany": true, "Hong Kong": true, "Italy": true,
+	"Korea": true, "Netherlands": true, "Scandinavia": true, "Spain": true,
+	"Sweden": true, "Taiwan": true, "UK": true, "Unknown": true,
+}
+
+// parseRegion returns the region from a No-Intro name, the first
+// parenthesised group made only of region names, as in
+// "Game (USA, Europe) (Rev 1)"
+func parseRegion(name string) string {
+	for {
+		start := strings.IndexByte(name, '(')
+		if start < 0 {
+			return ""
+		}
+		end := strings.IndexByte(name[start:], ')')
+		if end < 0 {
+			return ""
+		}
+
+		group := name[start+1 : start+end]
+		name = name[start+end+1:]
+
+		isRegion := true
+		for _, part := range strings.Split(group, ",") {
+			if !regions[strings.TrimSpace(part)] {
+				isRegion = false
+				break
+			}
+		}
+		if isRegion {
+			return group
+		}
+	}
+}